@@ -1,3 +1,4 @@
 #!/usr/bin/env bash
 
-env GOOS=linux GOARCH=amd64 go build -trimpath -ldflags="-s -w" -o foolish-mysql cmd/foolish-mysql/main.go
+env GOOS=linux GOARCH=amd64 go build -trimpath -ldflags="-s -w" -o foolish-mysql ./cmd/foolish-mysql
+env GOOS=linux GOARCH=arm64 go build -trimpath -ldflags="-s -w" -o foolish-mysql-aarch64 ./cmd/foolish-mysql
@@ -0,0 +1,84 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"foolishmysql/internal/installers"
+	"strconv"
+)
+
+// manage downloaded packages in cache dir
+func runCache(args []string) int {
+	var usage = func() {
+		fmt.Println("usage: ./foolish-mysql cache list [--cache-dir=DIR]\n       ./foolish-mysql cache prune --keep=N [--cache-dir=DIR]")
+	}
+	if len(args) == 0 {
+		usage()
+		return exitCodeFailed
+	}
+
+	var flagSet = flag.NewFlagSet("cache", flag.ExitOnError)
+	var cacheDir string
+	var keep int
+	flagSet.StringVar(&cacheDir, "cache-dir", installers.DefaultCacheDir, "dir to store downloaded packages")
+	flagSet.IntVar(&keep, "keep", -1, "keep packages of the latest N versions")
+	flagSet.Usage = func() {
+		usage()
+		flagSet.PrintDefaults()
+	}
+	_ = flagSet.Parse(args[1:])
+
+	var cache = installers.NewCache(cacheDir)
+	switch args[0] {
+	case "list":
+		items, err := cache.List()
+		if err != nil {
+			printError("list cache failed: " + err.Error())
+			return exitCodeFailed
+		}
+		if len(items) == 0 {
+			fmt.Println("no packages in cache dir '" + cache.Dir() + "'")
+			return 0
+		}
+		for _, item := range items {
+			var status = ""
+			if !item.Complete {
+				status = " (incomplete)"
+			}
+			fmt.Println(item.Version + "\t" + item.Flavour + "\t" + formatBytes(item.Size) + "\t" + item.ModTime.Format("2006-01-02 15:04:05") + "\t" + item.Path + status)
+		}
+	case "prune":
+		if keep < 0 {
+			printError("'--keep' is required")
+			return exitCodeFailed
+		}
+		removedItems, err := cache.Prune(keep)
+		for _, item := range removedItems {
+			fmt.Println("removed " + item.Path)
+		}
+		if err != nil {
+			printError("prune cache failed: " + err.Error())
+			return exitCodeFailed
+		}
+		fmt.Println(strconv.Itoa(len(removedItems)) + " packages removed")
+	default:
+		usage()
+		return exitCodeFailed
+	}
+	return 0
+}
+
+// format bytes to human readable size
+func formatBytes(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.2fG", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.2fM", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.2fK", float64(size)/(1<<10))
+	}
+	return strconv.FormatInt(size, 10) + "B"
+}
@@ -0,0 +1,314 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"foolishmysql/internal/installers"
+	"foolishmysql/pkg/installer"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// install mysql from archive file, or download it automatically
+func runInstall(ctx context.Context, args []string) int {
+	var flagSet = flag.NewFlagSet("foolish-mysql", flag.ExitOnError)
+	var targetDir string
+	var dataDir string
+	var tmpDir string
+	var full bool
+	var instance string
+	var port int
+	var socket string
+	var startTimeout time.Duration
+	var mysqlVersion string
+	var md5Sum string
+	var sha256Sum string
+	var verifySignature bool
+	var skipChecksum bool
+	var signatureFile string
+	var publicKeyFile string
+	var mirror string
+	var proxy string
+	var cacheDir string
+	var databaseSpecs stringList
+	var userSpecs stringList
+	var noHardening bool
+	var rootAuthSocket bool
+	var dryRun bool
+	var output string
+	var resume bool
+	var onlySteps string
+	var skipSteps string
+	var configFile string
+	flagSet.StringVar(&targetDir, "basedir", "", "mysql base dir to install, default is '/usr/local/mysql', or '/usr/local/mysql-${instance}' for instance")
+	flagSet.StringVar(&dataDir, "datadir", "", "mysql data dir, default is '${basedir}/data'")
+	flagSet.StringVar(&tmpDir, "tmpdir", "", "mysql temporary dir, default is system temporary dir")
+	flagSet.StringVar(&mysqlVersion, "version", "", "mysql version to download, such as '8.0.36', or '8.4' for latest release of the series, default is the latest release")
+	flagSet.BoolVar(&full, "full", false, "download full package instead of minimal one")
+	flagSet.StringVar(&instance, "instance", "", "instance name, used to run multiple mysql servers on one host")
+	flagSet.IntVar(&port, "port", installer.DefaultPort, "mysql server port")
+	flagSet.StringVar(&socket, "socket", "", "mysql unix socket file, default is '/tmp/mysql.sock', or '/tmp/mysql-${instance}.sock' for instance")
+	flagSet.DurationVar(&startTimeout, "start-timeout", installer.DefaultStartTimeout, "time to wait for the started server to accept connections")
+	flagSet.StringVar(&md5Sum, "md5", "", "expected md5 checksum of archive file")
+	flagSet.StringVar(&sha256Sum, "sha256", "", "expected sha256 checksum of archive file")
+	flagSet.BoolVar(&verifySignature, "verify-signature", false, "verify GPG signature of archive file")
+	flagSet.BoolVar(&skipChecksum, "skip-checksum", false, "install downloaded archive even if no published checksum is found on mysql CDN or mirror")
+	flagSet.StringVar(&signatureFile, "signature", "", "detached GPG signature file, default is '${archive}.asc', implies '--verify-signature'")
+	flagSet.StringVar(&publicKeyFile, "gpg-key", "", "public key to verify signature, default is the bundled mysql release key, implies '--verify-signature'")
+	flagSet.StringVar(&mirror, "mirror", "", "download from custom mirror url or local dir instead of mysql CDN, placeholders ${version}, ${major}, ${arch}, ${glibc}, ${flavour} and ${package} are supported")
+	flagSet.StringVar(&proxy, "proxy", "", "proxy url for downloading, default is from HTTP_PROXY and HTTPS_PROXY environment variables")
+	flagSet.StringVar(&cacheDir, "cache-dir", installer.DefaultCacheDir, "dir to store downloaded packages, set it to empty to download into current dir")
+	flagSet.Var(&databaseSpecs, "database", "database to create after root password is set, in format 'NAME[:CHARSET[:COLLATION]]', can be repeated")
+	flagSet.Var(&userSpecs, "user", "user to create after root password is set, in format 'NAME[@HOST][=DATABASE[:PRIVILEGE+PRIVILEGE...],...][:env=VAR|:file=PATH]', password is read from environment variable or file, or generated, can be repeated")
+	flagSet.BoolVar(&noHardening, "no-hardening", false, "keep anonymous accounts, test database and remote root accounts created by mysqld")
+	flagSet.BoolVar(&rootAuthSocket, "root-auth-socket", false, "switch 'root'@'localhost' to auth_socket after hardening, root can only login as system user 'root' with unix socket")
+	flagSet.BoolVar(&dryRun, "dry-run", false, "only check the system and print planned changes without making them")
+	flagSet.StringVar(&output, "output", outputText, "output format, 'text', or 'json' for line-delimited json events of steps and result, and json plan in dry-run mode")
+	flagSet.BoolVar(&resume, "resume", false, "continue the last failed or interrupted installation from its last successful step")
+	flagSet.StringVar(&onlySteps, "only", "", "only run these steps, separated by comma, available steps: "+strings.Join(installer.AllSteps, ", "))
+	flagSet.StringVar(&skipSteps, "skip", "", "bypass these steps, separated by comma")
+	flagSet.StringVar(&configFile, "config", "", "YAML or JSON file to configure the whole installation, options in command line take precedence")
+	flagSet.Usage = func() {
+		fmt.Println("usage: ./foolish-mysql [install] [OPTIONS] [ARCHIVE_FILE]")
+		flagSet.PrintDefaults()
+	}
+	_ = flagSet.Parse(args)
+
+	if output != outputText && output != outputJSON {
+		printError("invalid output format '" + output + "', should be 'text' or 'json'")
+		return exitCodeFailed
+	}
+	var printer = newOutputPrinter(output)
+
+	var archiveArg = flagSet.Arg(0)
+	var config *installers.InstallConfig
+	if len(configFile) > 0 {
+		var err error
+		config, err = installers.LoadInstallConfig(configFile)
+		if err != nil {
+			printer.Error("load config file '" + configFile + "' failed: " + err.Error())
+			return exitCodeFailed
+		}
+		err = applyConfigFlags(flagSet, config)
+		if err != nil {
+			printer.Error("load config file '" + configFile + "' failed: " + err.Error())
+			return exitCodeFailed
+		}
+		if len(archiveArg) == 0 && config.Source != nil {
+			archiveArg = config.Source.Archive
+		}
+	}
+
+	if flagSet.NArg() > 1 {
+		flagSet.Usage()
+		return exitCodeFailed
+	}
+
+	var opts = &installer.Options{
+		Archive:         archiveArg,
+		Version:         mysqlVersion,
+		Mirror:          mirror,
+		Proxy:           proxy,
+		CacheDir:        cacheDir,
+		MD5:             md5Sum,
+		SHA256:          sha256Sum,
+		VerifySignature: verifySignature,
+		SkipChecksum:    skipChecksum,
+		Signature:       signatureFile,
+		GPGKey:          publicKeyFile,
+		BaseDir:         targetDir,
+		DataDir:         dataDir,
+		TmpDir:          tmpDir,
+		Instance:        instance,
+		Port:            port,
+		Socket:          socket,
+		StartTimeout:    startTimeout,
+		NoHardening:     noHardening,
+		RootAuthSocket:  rootAuthSocket,
+		Resume:          resume,
+		OnlySteps:       splitList(onlySteps),
+		SkipSteps:       splitList(skipSteps),
+		DryRun:          dryRun,
+		Reporter:        printer,
+	}
+	if full {
+		opts.Flavour = installer.FlavourFull
+	}
+	if port <= 0 {
+		printer.Error("invalid port '" + strconv.Itoa(port) + "'")
+		return exitCodeFailed
+	}
+	if startTimeout <= 0 {
+		printer.Error("invalid start timeout '" + startTimeout.String() + "'")
+		return exitCodeFailed
+	}
+	if noHardening && rootAuthSocket {
+		printer.Error("'--root-auth-socket' can not be used with '--no-hardening'")
+		return exitCodeFailed
+	}
+	if config != nil {
+		if config.RootPassword != nil {
+			opts.RootPassword = config.RootPassword.Password
+			opts.PasswordLength = config.RootPassword.Length
+		}
+		if config.Service != nil {
+			opts.NoService = !config.Service.IsEnabled()
+			opts.ServiceRestart = config.Service.Restart
+		}
+		opts.MyCnf = config.MyCnf
+		opts.Dependencies = config.Dependencies
+		opts.Databases = config.Databases
+		opts.Users = config.Users
+	}
+
+	// databases and users in command line replace the ones in config file
+	if len(databaseSpecs) > 0 {
+		opts.Databases = []*installer.DatabaseConfig{}
+		for _, spec := range databaseSpecs {
+			database, err := installers.ParseDatabaseSpec(spec)
+			if err != nil {
+				printer.Error(err.Error())
+				return exitCodeFailed
+			}
+			opts.Databases = append(opts.Databases, database)
+		}
+	}
+	if len(userSpecs) > 0 {
+		opts.Users = []*installer.UserConfig{}
+		for _, spec := range userSpecs {
+			user, err := installers.ParseUserSpec(spec)
+			if err != nil {
+				printer.Error(err.Error())
+				return exitCodeFailed
+			}
+			opts.Users = append(opts.Users, user)
+		}
+	}
+
+	if dryRun {
+		printer.DisableEvents()
+	}
+	result, err := installer.Install(ctx, opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			printer.Failed("installation was interrupted", result)
+			return exitCodeInterrupted
+		}
+		printer.Failed(err.Error(), result)
+		return exitCodeFailed
+	}
+	if dryRun {
+		printPlan(result.Plan, output)
+	} else {
+		printer.Result(result)
+	}
+	return 0
+}
+
+// use options in config file as values of flags which are not set in command line
+func applyConfigFlags(flagSet *flag.FlagSet, config *installers.InstallConfig) error {
+	var values = map[string]string{
+		"version":       config.Version,
+		"basedir":       config.BaseDir,
+		"datadir":       config.DataDir,
+		"tmpdir":        config.TmpDir,
+		"instance":      config.Instance,
+		"socket":        config.Socket,
+		"start-timeout": config.StartTimeout,
+	}
+	if config.Flavour == installers.FlavourFull {
+		values["full"] = "true"
+	}
+	if config.Port > 0 {
+		values["port"] = strconv.Itoa(config.Port)
+	}
+	if config.Source != nil {
+		values["mirror"] = config.Source.Mirror
+		values["proxy"] = config.Source.Proxy
+		values["cache-dir"] = config.Source.CacheDir
+		values["md5"] = config.Source.MD5
+		values["sha256"] = config.Source.SHA256
+		values["signature"] = config.Source.Signature
+		values["gpg-key"] = config.Source.GPGKey
+		if config.Source.VerifySignature {
+			values["verify-signature"] = "true"
+		}
+		if config.Source.SkipChecksum {
+			values["skip-checksum"] = "true"
+		}
+	}
+	if !config.Hardening.IsEnabled() {
+		values["no-hardening"] = "true"
+	}
+	if config.Hardening != nil && config.Hardening.RootAuthSocket {
+		values["root-auth-socket"] = "true"
+	}
+
+	var visited = map[string]bool{}
+	flagSet.Visit(func(f *flag.Flag) {
+		visited[f.Name] = true
+	})
+	for name, value := range values {
+		if len(value) == 0 || visited[name] {
+			continue
+		}
+		err := flagSet.Set(name, value)
+		if err != nil {
+			return errors.New("invalid value of '" + name + "': " + err.Error())
+		}
+	}
+	return nil
+}
+
+// print rolled back steps after installation failed
+func printRolledBack(rolledBack []string) {
+	if len(rolledBack) > 0 {
+		fmt.Println("rolled back:")
+		for _, step := range rolledBack {
+			fmt.Println("  " + step)
+		}
+	}
+}
+
+// values of repeatable flag
+type stringList []string
+
+func (this *stringList) String() string {
+	return strings.Join(*this, ", ")
+}
+
+func (this *stringList) Set(value string) error {
+	*this = append(*this, value)
+	return nil
+}
+
+// split comma separated list
+func splitList(s string) []string {
+	var result = []string{}
+	for _, piece := range strings.Split(s, ",") {
+		piece = strings.TrimSpace(piece)
+		if len(piece) > 0 {
+			result = append(result, piece)
+		}
+	}
+	return result
+}
+
+// print planned changes of dry-run
+func printPlan(plan *installer.Plan, output string) {
+	if output == "json" {
+		data, err := plan.JSON()
+		if err != nil {
+			printError("encode plan failed: " + err.Error())
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Print(plan.String())
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"foolishmysql/internal/installers"
+	"github.com/fatih/color"
+	"os"
+	"strings"
+)
+
+// uninstall mysql installed by foolish-mysql
+func runUninstall(args []string) int {
+	var flagSet = flag.NewFlagSet("uninstall", flag.ExitOnError)
+	var instance string
+	var purgeData bool
+	var yes bool
+	flagSet.StringVar(&instance, "instance", "", "instance name to uninstall")
+	flagSet.BoolVar(&purgeData, "purge-data", false, "also delete data dir, 'mysql' user and group")
+	flagSet.BoolVar(&yes, "yes", false, "do not ask for confirmation of '--purge-data'")
+	flagSet.Usage = func() {
+		fmt.Println("usage: ./foolish-mysql uninstall [--instance=NAME] [--purge-data [--yes]]")
+		flagSet.PrintDefaults()
+	}
+	_ = flagSet.Parse(args)
+
+	var installer = installers.NewFoolishInstaller()
+	if len(instance) > 0 {
+		err := installers.ValidateInstanceName(instance)
+		if err != nil {
+			printError(err.Error())
+			return exitCodeFailed
+		}
+		installer.WithInstance(instance)
+	}
+
+	baseDir, dataDir, err := installer.InstalledDirs()
+	if err != nil {
+		printError(err.Error())
+		return exitCodeFailed
+	}
+
+	if purgeData && !yes {
+		fmt.Print("data dir '" + dataDir + "' will be deleted and could not be recovered, type 'yes' to continue: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "yes" {
+			fmt.Println("canceled")
+			return exitCodeFailed
+		}
+	}
+
+	removed, err := installer.Uninstall(purgeData)
+	for _, step := range removed {
+		fmt.Println(step)
+	}
+	if err != nil {
+		printError("uninstall failed: " + err.Error())
+		return exitCodeFailed
+	}
+	_, _ = color.New(color.FgGreen).Println("uninstalled '" + baseDir + "' successfully")
+	if !purgeData {
+		fmt.Println("data dir '" + dataDir + "' was kept, use '--purge-data' to delete it")
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"foolishmysql/internal/installers"
+	"github.com/fatih/color"
+	"os"
+	"time"
+)
+
+// upgrade installed mysql to a new release from archive file or version
+func runUpgrade(ctx context.Context, args []string) int {
+	var flagSet = flag.NewFlagSet("upgrade", flag.ExitOnError)
+	var instance string
+	var full bool
+	var md5Sum string
+	var sha256Sum string
+	var verifySignature bool
+	var skipChecksum bool
+	var signatureFile string
+	var publicKeyFile string
+	var mirror string
+	var proxy string
+	var cacheDir string
+	var startTimeout time.Duration
+	flagSet.StringVar(&instance, "instance", "", "instance name to upgrade")
+	flagSet.BoolVar(&full, "full", false, "download full package instead of minimal one")
+	flagSet.StringVar(&md5Sum, "md5", "", "expected md5 checksum of archive file")
+	flagSet.StringVar(&sha256Sum, "sha256", "", "expected sha256 checksum of archive file")
+	flagSet.BoolVar(&verifySignature, "verify-signature", false, "verify GPG signature of archive file")
+	flagSet.BoolVar(&skipChecksum, "skip-checksum", false, "install downloaded archive even if no published checksum is found on mysql CDN or mirror")
+	flagSet.StringVar(&signatureFile, "signature", "", "detached GPG signature file, default is '${archive}.asc', implies '--verify-signature'")
+	flagSet.StringVar(&publicKeyFile, "gpg-key", "", "public key to verify signature, default is the bundled mysql release key, implies '--verify-signature'")
+	flagSet.StringVar(&mirror, "mirror", "", "download from custom mirror url or local dir instead of mysql CDN")
+	flagSet.StringVar(&proxy, "proxy", "", "proxy url for downloading, default is from HTTP_PROXY and HTTPS_PROXY environment variables")
+	flagSet.StringVar(&cacheDir, "cache-dir", installers.DefaultCacheDir, "dir to store downloaded packages, set it to empty to download into current dir")
+	flagSet.DurationVar(&startTimeout, "start-timeout", installers.DefaultUpgradeTimeout, "time to wait for the new server to accept connections, data dictionary upgrade may take a while on big data dirs")
+	flagSet.Usage = func() {
+		fmt.Println("usage: ./foolish-mysql upgrade [OPTIONS] ARCHIVE_FILE|VERSION")
+		flagSet.PrintDefaults()
+	}
+	_ = flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return exitCodeFailed
+	}
+	if startTimeout <= 0 {
+		printError("invalid start timeout '" + startTimeout.String() + "'")
+		return exitCodeFailed
+	}
+
+	var installer = installers.NewFoolishInstaller().WithContext(ctx)
+	if len(instance) > 0 {
+		err := installers.ValidateInstanceName(instance)
+		if err != nil {
+			printError(err.Error())
+			return exitCodeFailed
+		}
+		installer.WithInstance(instance)
+	}
+	if full {
+		installer.WithFlavour(installers.FlavourFull)
+	}
+	if len(mirror) > 0 {
+		m, err := installers.NewMirror(mirror)
+		if err != nil {
+			printError(err.Error())
+			return exitCodeFailed
+		}
+		installer.WithMirror(m)
+	}
+	installer.WithProxy(proxy)
+	if len(cacheDir) > 0 {
+		installer.WithCache(installers.NewCache(cacheDir))
+	}
+	installer.WithChecksums(md5Sum, sha256Sum)
+	installer.WithSkipChecksum(skipChecksum)
+	if verifySignature || len(signatureFile) > 0 || len(publicKeyFile) > 0 {
+		installer.WithSignature(signatureFile, publicKeyFile)
+	}
+
+	// archive file, or version to download
+	var archiveFile = flagSet.Arg(0)
+	_, err := os.Stat(archiveFile)
+	if err != nil {
+		_, _, selectorErr := installers.ParseVersionSelector(archiveFile)
+		if selectorErr != nil {
+			printError("'" + archiveFile + "' is neither an archive file nor a mysql version")
+			return exitCodeFailed
+		}
+		installer.WithVersion(archiveFile)
+		archiveFile, err = installer.Download()
+		if err != nil {
+			if ctx.Err() != nil {
+				printError("download was interrupted")
+				return exitCodeFailed
+			}
+			printError("download failed: " + err.Error())
+			return exitCodeFailed
+		}
+	}
+
+	result, err := installer.Upgrade(archiveFile, startTimeout)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = errors.New("interrupted")
+		}
+		printError("upgrade failed: " + err.Error())
+		printRolledBack(installer.RolledBack())
+		return exitCodeFailed
+	}
+	_, _ = color.New(color.FgGreen).Println("upgraded successfully\n=======\nversion: v" + result.OldVersion + " -> v" + result.NewVersion + "\ndir: " + result.BaseDir + " -> " + result.ReleaseDir)
+	fmt.Println("old release is kept in '" + result.OldDir + "', remove it after checking the new server")
+	return 0
+}
@@ -3,16 +3,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"foolishmysql/internal/installers"
 	"github.com/fatih/color"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
+)
+
+// exit codes of commands
+const (
+	exitCodeFailed      = 1   // command failed, or its arguments are invalid
+	exitCodeInterrupted = 130 // installation was interrupted by SIGINT or SIGTERM
 )
 
 func main() {
 	var args = os.Args
-	if len(args) > 1 {
+	if len(args) == 2 {
+		// '--version' with a value is used to choose mysql version
 		var cmd = args[1]
 		if cmd == "-v" || cmd == "--version" || cmd == "version" {
 			fmt.Println(installers.Version)
@@ -20,40 +29,55 @@ func main() {
 		}
 	}
 
-	var installer = installers.NewFoolishInstaller()
-	var targetDir = "/usr/local/mysql"
-
-	// check target dir
-	_, err := os.Stat(targetDir)
-	if err == nil {
-		// check target dir
-		matches, _ := filepath.Glob(targetDir + "/*")
-		if len(matches) > 0 {
-			_, _ = color.New(color.FgRed).Println("target dir '" + targetDir + "' already exists and not empty, please check if you are using the directory")
-			return
-		}
+	var command = ""
+	if len(args) > 1 {
+		command = args[1]
 	}
-
-	var xzFile string
-	if len(args) == 1 {
-		xzFile, err = installer.Download()
-		if err != nil {
-			_, _ = color.New(color.FgRed).Println("download failed: " + err.Error())
-			return
-		}
-	} else if len(args) == 2 {
-		xzFile = args[1]
+	var exitCode = 0
+	switch command {
+	case "install":
+		exitCode = runInterruptible(func(ctx context.Context) int {
+			return runInstall(ctx, args[2:])
+		})
+	case "cache":
+		exitCode = runCache(args[2:])
+	case "uninstall":
+		exitCode = runUninstall(args[2:])
+	case "upgrade":
+		exitCode = runInterruptible(func(ctx context.Context) int {
+			return runUpgrade(ctx, args[2:])
+		})
+	default:
+		// install by default
+		exitCode = runInterruptible(func(ctx context.Context) int {
+			return runInstall(ctx, args[1:])
+		})
 	}
-
-	if len(xzFile) == 0 {
-		fmt.Println("usage: ./foolish-mysql or ./foolish-mysql XZ_FILE")
-		return
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
+}
 
-	err = installer.InstallFromFile(xzFile, targetDir)
-	if err != nil {
-		_, _ = color.New(color.FgRed).Println("install from file '" + xzFile + "' failed: " + err.Error())
-	} else {
-		_, _ = color.New(color.FgGreen).Println("installed successfully\n=======\nuser: root\npassword: " + installer.Password() + "\ndir: " + targetDir)
+// run command with a context canceled by SIGINT or SIGTERM, exit code is exitCodeInterrupted after canceled
+// command should stop and roll back its changes after canceled, press Ctrl-C again to exit immediately
+func runInterruptible(run func(ctx context.Context) int) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+
+		// restore default behavior of signals
+		stop()
+	}()
+
+	var exitCode = run(ctx)
+	if ctx.Err() != nil {
+		return exitCodeInterrupted
 	}
+	stop()
+	return exitCode
+}
+
+// print error message in red
+func printError(message string) {
+	_, _ = color.New(color.FgRed).Println(message)
 }
@@ -0,0 +1,172 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"foolishmysql/pkg/installer"
+	"github.com/fatih/color"
+	"strconv"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// event type in json output
+const (
+	outputEventStep   = "step"
+	outputEventResult = "result"
+)
+
+// step event in json output
+type outputStepEvent struct {
+	Type string `json:"type"`
+	*installer.StepEvent
+}
+
+// result in json output
+type outputResult struct {
+	Type       string   `json:"type"`
+	Status     string   `json:"status"` // success or failed
+	Error      string   `json:"error,omitempty"`
+	RolledBack []string `json:"rolledBack,omitempty"`
+	StateFile  string   `json:"stateFile,omitempty"` // state file to resume failed installation
+	*installer.InstallResult
+	Users []*outputUser `json:"users,omitempty"` // created users with their generated passwords
+}
+
+// created user in result, supplied passwords are not printed to keep them out of logs
+type outputUser struct {
+	Name             string                   `json:"name"`
+	Host             string                   `json:"host"`
+	Password         string                   `json:"password,omitempty"`         // generated password
+	PasswordSupplied bool                     `json:"passwordSupplied,omitempty"` // password was supplied by config file, environment variable or file
+	Grants           []*installer.GrantConfig `json:"grants,omitempty"`
+}
+
+func newOutputUsers(users []*installer.UserConfig) []*outputUser {
+	var result = []*outputUser{}
+	for _, user := range users {
+		var outUser = &outputUser{
+			Name:   user.Name,
+			Host:   user.Host,
+			Grants: user.Grants,
+		}
+		if user.PasswordGenerated {
+			outUser.Password = user.Password
+		} else {
+			outUser.PasswordSupplied = true
+		}
+		result = append(result, outUser)
+	}
+	return result
+}
+
+// outputPrinter print messages of installation as colored text, or line-delimited json events for automation
+// it is also the reporter of installer, logs are always printed by console reporter to stderr
+type outputPrinter struct {
+	*installer.ConsoleReporter
+
+	format string
+	events bool // whether to print step events in json output
+}
+
+func newOutputPrinter(format string) *outputPrinter {
+	return &outputPrinter{
+		ConsoleReporter: installer.NewConsoleReporter(),
+		format:          format,
+		events:          true,
+	}
+}
+
+// DisableEvents stop printing step events, such as in dry-run mode which prints a json plan
+func (this *outputPrinter) DisableEvents() {
+	this.events = false
+}
+
+// IsJSON check whether output is json
+func (this *outputPrinter) IsJSON() bool {
+	return this.format == outputJSON
+}
+
+// StepStarted print step event in json output
+func (this *outputPrinter) StepStarted(step installer.StepName) {
+	if !this.IsJSON() || !this.events {
+		this.ConsoleReporter.StepStarted(step)
+		return
+	}
+	this.printJSON(&outputStepEvent{Type: outputEventStep, StepEvent: &installer.StepEvent{Name: step, Status: installer.StepStatusStarted}})
+}
+
+// StepFinished print step event in json output
+func (this *outputPrinter) StepFinished(event *installer.StepEvent) {
+	if !this.IsJSON() || !this.events {
+		this.ConsoleReporter.StepFinished(event)
+		return
+	}
+	this.printJSON(&outputStepEvent{Type: outputEventStep, StepEvent: event})
+}
+
+// Error print error before installation started
+func (this *outputPrinter) Error(message string) {
+	if this.IsJSON() {
+		this.printJSON(&outputResult{Type: outputEventResult, Status: "failed", Error: message})
+		return
+	}
+	printError(message)
+}
+
+// Failed print error of installation, with rolled back steps and state file to resume
+func (this *outputPrinter) Failed(message string, result *installer.Result) {
+	if this.IsJSON() {
+		this.printJSON(&outputResult{Type: outputEventResult, Status: "failed", Error: message, RolledBack: result.RolledBack, StateFile: result.StateFile})
+		return
+	}
+
+	printError(message)
+	printRolledBack(result.RolledBack)
+	if len(result.StateFile) > 0 {
+		fmt.Println("state of installation was saved to '" + result.StateFile + "', run with '--resume' to continue after fixing the problem")
+	}
+}
+
+// Result print result of successful installation
+func (this *outputPrinter) Result(result *installer.Result) {
+	if this.IsJSON() {
+		this.printJSON(&outputResult{Type: outputEventResult, Status: "success", InstallResult: result.InstallResult, Users: newOutputUsers(result.Users)})
+		return
+	}
+
+	var password = result.RootPassword
+	if result.RootAuthSocket {
+		password = "(auth_socket, login as system user 'root' with unix socket)"
+	}
+	var message = "installed successfully\n=======\nversion: " + result.Version + "\nuser: root\npassword: " + password + "\ndir: " + result.BaseDir + "\ndatadir: " + result.DataDir + "\nport: " + strconv.Itoa(result.Port) + "\nsocket: " + result.Socket + "\nconfig: " + result.ConfigFile + "\nservice: " + result.ServiceName
+	if len(result.CredentialFile) > 0 {
+		message += "\ncredential file: " + result.CredentialFile
+	}
+	_, _ = color.New(color.FgGreen).Println(message)
+	for _, change := range result.Hardening {
+		_, _ = color.New(color.FgGreen).Println("hardening: " + change)
+	}
+	for _, user := range newOutputUsers(result.Users) {
+		var userPassword = user.Password
+		if user.PasswordSupplied {
+			userPassword = "(supplied)"
+		}
+		_, _ = color.New(color.FgGreen).Println("user: '" + user.Name + "'@'" + user.Host + "', password: " + userPassword)
+	}
+}
+
+// print one line json
+func (this *outputPrinter) printJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		printError("encode json failed: " + err.Error())
+		return
+	}
+	fmt.Println(string(data))
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+	"foolishmysql/internal/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCacheDir default dir to store downloaded packages
+const DefaultCacheDir = "/var/cache/foolish-mysql"
+
+// CacheItem cached package
+type CacheItem struct {
+	Version  string
+	Flavour  Flavour
+	Path     string
+	Size     int64
+	ModTime  time.Time
+	Complete bool // false if the package is still being downloaded
+}
+
+// Cache store downloaded packages in '${dir}/${version}/${flavour}/${package}',
+// with checksum file '${package}.sha256' written after verified
+type Cache struct {
+	dir string
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir: dir,
+	}
+}
+
+// Dir get cache dir
+func (this *Cache) Dir() string {
+	return this.dir
+}
+
+// PackagePath get path of the package in cache, parent dirs will be created
+func (this *Cache) PackagePath(pkg *PackageInfo) (string, error) {
+	var path = this.packageFile(pkg)
+	var dir = filepath.Dir(path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", errors.New("create cache dir '" + dir + "' failed: " + err.Error())
+	}
+	return path, nil
+}
+
+// Lookup find the package in cache, the package will be reused only if its checksum still matches
+func (this *Cache) Lookup(pkg *PackageInfo) (path string, ok bool) {
+	path = this.packageFile(pkg)
+	checksumData, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return "", false
+	}
+	var fields = strings.Fields(string(checksumData))
+	if len(fields) == 0 {
+		return "", false
+	}
+	err = utils.VerifyChecksums(path, "", fields[0])
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Store record checksum of the verified package
+func (this *Cache) Store(path string) error {
+	_, sha256Sum, err := utils.FileChecksums(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".sha256", []byte(sha256Sum+"  "+filepath.Base(path)+"\n"), 0644)
+}
+
+// List list cached packages, newer versions first
+func (this *Cache) List() ([]*CacheItem, error) {
+	var result = []*CacheItem{}
+	matches, err := filepath.Glob(this.dir + "/*/*/mysql-*")
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		var name = filepath.Base(match)
+		if strings.HasSuffix(name, ".sha256") || strings.HasSuffix(name, ".asc") {
+			continue
+		}
+
+		stat, err := os.Stat(match)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+
+		var isPart = strings.HasSuffix(name, ".part")
+		var complete = false
+		if !isPart {
+			_, err = os.Stat(match + ".sha256")
+			complete = err == nil
+		}
+
+		var flavourDir = filepath.Dir(match)
+		result = append(result, &CacheItem{
+			Version:  filepath.Base(filepath.Dir(flavourDir)),
+			Flavour:  filepath.Base(flavourDir),
+			Path:     match,
+			Size:     stat.Size(),
+			ModTime:  stat.ModTime(),
+			Complete: complete,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		var c = utils.VersionCompare(result[i].Version, result[j].Version)
+		if c == 0 {
+			return result[i].Flavour < result[j].Flavour
+		}
+		return c > 0
+	})
+
+	return result, nil
+}
+
+// Prune keep packages of the latest N versions, and remove others
+func (this *Cache) Prune(keep int) (removedItems []*CacheItem, err error) {
+	if keep < 0 {
+		return nil, errors.New("invalid keep count")
+	}
+
+	items, err := this.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var keptVersions = map[string]bool{}
+	for _, item := range items {
+		if keptVersions[item.Version] {
+			continue
+		}
+		if len(keptVersions) < keep {
+			keptVersions[item.Version] = true
+			continue
+		}
+
+		err = os.RemoveAll(this.dir + "/" + item.Version)
+		if err != nil {
+			return removedItems, errors.New("remove '" + item.Path + "' failed: " + err.Error())
+		}
+		removedItems = append(removedItems, item)
+	}
+
+	return removedItems, nil
+}
+
+// path of the package in cache
+func (this *Cache) packageFile(pkg *PackageInfo) string {
+	return this.dir + "/" + pkg.Version + "/" + pkg.Flavour + "/" + pkg.Name
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"foolishmysql/internal/installers"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	var mirrorDir = t.TempDir()
+	writeTestMirror(t, mirrorDir, []string{"8.0.35", "8.0.36", "8.4.0"})
+
+	var packageRequests = 0
+	var fileServer = http.FileServer(http.Dir(mirrorDir))
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, ".tar.xz") {
+			packageRequests++
+		}
+		fileServer.ServeHTTP(writer, req)
+	}))
+	defer server.Close()
+
+	mirror, err := installers.NewMirror(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cache = installers.NewCache(t.TempDir())
+
+	for _, version := range []string{"8.0.35", "8.0.36", "8.4.0", "8.0.36"} {
+		path, err := installers.NewFoolishInstaller().
+			WithPlatform("x86_64", "2.17").
+			WithMirror(mirror).
+			WithCache(cache).
+			WithVersion(version).
+			Download()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(path, cache.Dir()+"/"+version+"/minimal/") {
+			t.Fatal("invalid cache path:", path)
+		}
+	}
+	if packageRequests != 3 {
+		t.Fatal("cached package should be reused, but got", packageRequests, "requests")
+	}
+
+	// corrupted package should be downloaded again
+	path, _ := cache.Lookup(&installers.PackageInfo{Version: "8.4.0", Flavour: installers.FlavourMinimal, Name: "mysql-8.4.0-linux-glibc2.17-x86_64-minimal.tar.xz"})
+	err = os.WriteFile(path, []byte("corrupted"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithMirror(mirror).
+		WithCache(cache).
+		WithVersion("8.4.0").
+		Download()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packageRequests != 4 {
+		t.Fatal("corrupted package should be downloaded again")
+	}
+
+	items, err := cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0].Version != "8.4.0" {
+		t.Fatal("invalid cache items")
+	}
+
+	removedItems, err := cache.Prune(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removedItems) != 2 {
+		t.Fatal("expect 2 removed items, but got", len(removedItems))
+	}
+	items, err = cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Version != "8.4.0" {
+		t.Fatal("latest version should be kept")
+	}
+}
@@ -0,0 +1,614 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type DependencyPolicy = string
+
+const (
+	DependenciesInstall DependencyPolicy = "install" // install missing shared libraries with package manager
+	DependenciesCheck   DependencyPolicy = "check"   // only check shared libraries, fail if any is missing
+	DependenciesSkip    DependencyPolicy = "skip"    // do not check or install shared libraries
+)
+
+const (
+	DefaultPasswordLength = 32
+	DefaultUserHost       = "localhost"
+)
+
+var databaseNameReg = regexp.MustCompile(`^[a-zA-Z0-9_$]{1,64}$`)
+var charsetNameReg = regexp.MustCompile(`^[a-zA-Z0-9_]{1,64}$`)
+var userNameReg = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,32}$`)
+var userHostReg = regexp.MustCompile(`^[a-zA-Z0-9_.%:/-]{1,255}$`)
+var privilegeReg = regexp.MustCompile(`^[A-Za-z]+( [A-Za-z]+)*$`)
+var myCnfKeyReg = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// my.cnf options managed by installer, they can not be overridden
+var reservedMyCnfKeys = map[string]string{
+	"port":    "port",
+	"basedir": "baseDir",
+	"datadir": "dataDir",
+	"socket":  "socket",
+	"tmpdir":  "tmpDir",
+}
+
+// InstallConfig non-interactive configuration of the whole installation, loaded from YAML or JSON file
+type InstallConfig struct {
+	Version      string              `json:"version"` // version selector, such as '8.0.36' or '8.4'
+	Flavour      Flavour             `json:"flavour"`
+	Source       *SourceConfig       `json:"source"`
+	BaseDir      string              `json:"baseDir"`
+	DataDir      string              `json:"dataDir"`
+	TmpDir       string              `json:"tmpDir"`
+	Instance     string              `json:"instance"`
+	Port         int                 `json:"port"`
+	Socket       string              `json:"socket"`
+	StartTimeout string              `json:"startTimeout"` // time to wait for the started server, such as '5m'
+	RootPassword *RootPasswordConfig `json:"rootPassword"`
+	Databases    []*DatabaseConfig   `json:"databases"`
+	Users        []*UserConfig       `json:"users"`
+	MyCnf        map[string]string   `json:"myCnf"` // options of [mysqld] section in my.cnf
+	Service      *ServiceConfig      `json:"service"`
+	Hardening    *HardeningConfig    `json:"hardening"`
+	Dependencies DependencyPolicy    `json:"dependencies"`
+}
+
+// SourceConfig where to get the installer file
+type SourceConfig struct {
+	Archive         string `json:"archive"` // local archive file, downloaded if empty
+	Mirror          string `json:"mirror"`
+	Proxy           string `json:"proxy"`
+	CacheDir        string `json:"cacheDir"`
+	MD5             string `json:"md5"`
+	SHA256          string `json:"sha256"`
+	VerifySignature bool   `json:"verifySignature"`
+	SkipChecksum    bool   `json:"skipChecksum"` // install downloaded archive even if no published checksum is found
+	Signature       string `json:"signature"`
+	GPGKey          string `json:"gpgKey"`
+}
+
+// RootPasswordConfig policy of root password
+type RootPasswordConfig struct {
+	Password string `json:"password"` // fixed password, a random one will be generated if empty
+	Length   int    `json:"length"`   // length of generated password
+}
+
+// DatabaseConfig database to create after installation
+type DatabaseConfig struct {
+	Name      string `json:"name"`
+	Charset   string `json:"charset"`
+	Collation string `json:"collation"`
+}
+
+// UserConfig user to create after installation
+type UserConfig struct {
+	Name     string         `json:"name"`
+	Host     string         `json:"host"`
+	Password string         `json:"password"` // a random one will be generated if empty
+	Grants   []*GrantConfig `json:"grants,omitempty"`
+
+	PasswordGenerated bool `json:"passwordGenerated,omitempty"` // password was generated by installer, it can not be set in config file
+}
+
+// GrantConfig privileges of user on a database
+type GrantConfig struct {
+	Database   string   `json:"database"`             // database name, or '*' for all databases
+	Privileges []string `json:"privileges,omitempty"` // default is 'ALL PRIVILEGES'
+}
+
+// ServiceConfig settings of systemd service
+type ServiceConfig struct {
+	Enabled *bool  `json:"enabled"` // default is true
+	Restart string `json:"restart"` // Restart= option of service unit, default is 'on-failure'
+}
+
+// IsEnabled check whether the service should be registered
+func (this *ServiceConfig) IsEnabled() bool {
+	return this == nil || this.Enabled == nil || *this.Enabled
+}
+
+// HardeningConfig settings of securing the new server like mysql_secure_installation
+type HardeningConfig struct {
+	Enabled        *bool `json:"enabled"`        // default is true
+	RootAuthSocket bool  `json:"rootAuthSocket"` // switch 'root'@'localhost' to auth_socket
+}
+
+// IsEnabled check whether the server should be hardened
+func (this *HardeningConfig) IsEnabled() bool {
+	return this == nil || this.Enabled == nil || *this.Enabled
+}
+
+// kinds of values in config file
+const (
+	configKindString = "string"
+	configKindInt    = "int"
+	configKindBool   = "bool"
+	configKindScalar = "scalar" // string, number or bool
+	configKindObject = "object"
+	configKindList   = "list"
+	configKindMap    = "map"
+)
+
+// schema of a value in config file
+type configSchema struct {
+	kind   string
+	fields map[string]*configSchema // fields of object
+	item   *configSchema            // item of list, or value of map
+}
+
+var installConfigSchema = &configSchema{kind: configKindObject, fields: map[string]*configSchema{
+	"version": {kind: configKindString},
+	"flavour": {kind: configKindString},
+	"source": {kind: configKindObject, fields: map[string]*configSchema{
+		"archive":         {kind: configKindString},
+		"mirror":          {kind: configKindString},
+		"proxy":           {kind: configKindString},
+		"cacheDir":        {kind: configKindString},
+		"md5":             {kind: configKindString},
+		"sha256":          {kind: configKindString},
+		"verifySignature": {kind: configKindBool},
+		"skipChecksum":    {kind: configKindBool},
+		"signature":       {kind: configKindString},
+		"gpgKey":          {kind: configKindString},
+	}},
+	"baseDir":      {kind: configKindString},
+	"dataDir":      {kind: configKindString},
+	"tmpDir":       {kind: configKindString},
+	"instance":     {kind: configKindString},
+	"port":         {kind: configKindInt},
+	"socket":       {kind: configKindString},
+	"startTimeout": {kind: configKindString},
+	"rootPassword": {kind: configKindObject, fields: map[string]*configSchema{
+		"password": {kind: configKindString},
+		"length":   {kind: configKindInt},
+	}},
+	"databases": {kind: configKindList, item: &configSchema{kind: configKindObject, fields: map[string]*configSchema{
+		"name":      {kind: configKindString},
+		"charset":   {kind: configKindString},
+		"collation": {kind: configKindString},
+	}}},
+	"users": {kind: configKindList, item: &configSchema{kind: configKindObject, fields: map[string]*configSchema{
+		"name":     {kind: configKindString},
+		"host":     {kind: configKindString},
+		"password": {kind: configKindString},
+		"grants": {kind: configKindList, item: &configSchema{kind: configKindObject, fields: map[string]*configSchema{
+			"database":   {kind: configKindString},
+			"privileges": {kind: configKindList, item: &configSchema{kind: configKindString}},
+		}}},
+	}}},
+	"myCnf": {kind: configKindMap, item: &configSchema{kind: configKindScalar}},
+	"service": {kind: configKindObject, fields: map[string]*configSchema{
+		"enabled": {kind: configKindBool},
+		"restart": {kind: configKindString},
+	}},
+	"hardening": {kind: configKindObject, fields: map[string]*configSchema{
+		"enabled":        {kind: configKindBool},
+		"rootAuthSocket": {kind: configKindBool},
+	}},
+	"dependencies": {kind: configKindString},
+}}
+
+// LoadInstallConfig load config from YAML or JSON file, '.json' files are parsed as JSON
+func LoadInstallConfig(path string) (*InstallConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseInstallConfig(data, strings.ToLower(filepath.Ext(path)) == ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	// files in source are relative to config file
+	if config.Source != nil {
+		for _, file := range []*string{&config.Source.Archive, &config.Source.Signature, &config.Source.GPGKey} {
+			if len(*file) > 0 && !filepath.IsAbs(*file) {
+				*file = filepath.Join(filepath.Dir(path), *file)
+			}
+		}
+	}
+	return config, nil
+}
+
+// ParseInstallConfig parse and validate config data
+func ParseInstallConfig(data []byte, isJSON bool) (*InstallConfig, error) {
+	var value interface{}
+	if isJSON {
+		var decoder = json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err := decoder.Decode(&value)
+		if err != nil {
+			return nil, errors.New("invalid json: " + err.Error())
+		}
+	} else {
+		err := yaml.Unmarshal(data, &value)
+		if err != nil {
+			return nil, errors.New("invalid yaml: " + err.Error())
+		}
+	}
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+
+	value, err := normalizeConfigValue("", value, installConfigSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode into struct
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var config = &InstallConfig{}
+	err = json.Unmarshal(jsonData, config)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ParseDatabaseSpec parse database from command line, in format 'NAME[:CHARSET[:COLLATION]]', such as 'app:utf8mb4:utf8mb4_general_ci'
+func ParseDatabaseSpec(spec string) (*DatabaseConfig, error) {
+	var pieces = strings.Split(spec, ":")
+	if len(pieces) > 3 || len(pieces[0]) == 0 {
+		return nil, errors.New("invalid database '" + spec + "', should be 'NAME[:CHARSET[:COLLATION]]'")
+	}
+	var database = &DatabaseConfig{Name: pieces[0]}
+	if len(pieces) > 1 {
+		database.Charset = pieces[1]
+	}
+	if len(pieces) > 2 {
+		database.Collation = pieces[2]
+	}
+	return database, nil
+}
+
+// ParseUserSpec parse user from command line, in format 'NAME[@HOST][=DATABASE[:PRIVILEGE+PRIVILEGE...],...][:env=VAR|:file=PATH]', such as 'app@%=app:SELECT+INSERT,logs:env=APP_PASSWORD'
+// privileges default to 'ALL PRIVILEGES', '*' means all databases
+// password is read from environment variable or file to keep it out of process list, or generated if no source is given
+func ParseUserSpec(spec string) (*UserConfig, error) {
+	// password source is the last part, path of file may contain ':' and '='
+	var account = spec
+	var password = ""
+	var sourceIndex = -1
+	for _, prefix := range []string{":env=", ":file="} {
+		var index = strings.Index(spec, prefix)
+		if index >= 0 && (sourceIndex < 0 || index < sourceIndex) {
+			sourceIndex = index
+		}
+	}
+	if sourceIndex >= 0 {
+		account = spec[:sourceIndex]
+		var kind, source, _ = strings.Cut(spec[sourceIndex+1:], "=")
+		if len(source) == 0 {
+			return nil, errors.New("invalid password source of user '" + spec + "'")
+		}
+		if kind == "env" {
+			value, ok := os.LookupEnv(source)
+			if !ok {
+				return nil, errors.New("environment variable '" + source + "' of user '" + spec + "' is not set")
+			}
+			password = value
+		} else {
+			data, err := os.ReadFile(source)
+			if err != nil {
+				return nil, errors.New("read password file of user '" + spec + "' failed: " + err.Error())
+			}
+			password = strings.TrimRight(string(data), "\r\n")
+		}
+		if len(password) == 0 {
+			return nil, errors.New("password of user '" + spec + "' should not be empty")
+		}
+	}
+
+	account, grants, hasGrants := strings.Cut(account, "=")
+	var name, host, _ = strings.Cut(account, "@")
+	if len(name) == 0 || (hasGrants && len(grants) == 0) {
+		return nil, errors.New("invalid user '" + spec + "', should be 'NAME[@HOST][=DATABASE[:PRIVILEGE+PRIVILEGE...],...][:env=VAR|:file=PATH]'")
+	}
+	var user = &UserConfig{Name: name, Host: host, Password: password}
+	if hasGrants {
+		for _, grantSpec := range strings.Split(grants, ",") {
+			var database, privileges, hasPrivileges = strings.Cut(grantSpec, ":")
+			if len(database) == 0 || (hasPrivileges && len(privileges) == 0) {
+				return nil, errors.New("invalid grant '" + grantSpec + "' of user '" + spec + "'")
+			}
+			var grant = &GrantConfig{Database: database}
+			if hasPrivileges {
+				grant.Privileges = strings.Split(privileges, "+")
+			}
+			user.Grants = append(user.Grants, grant)
+		}
+	}
+	return user, nil
+}
+
+// Validate check values of config, errors point at the offending key
+func (this *InstallConfig) Validate() error {
+	if len(this.Version) > 0 {
+		_, _, err := ParseVersionSelector(this.Version)
+		if err != nil {
+			return configError("version", err.Error())
+		}
+	}
+	if len(this.Flavour) > 0 && this.Flavour != FlavourMinimal && this.Flavour != FlavourFull {
+		return configError("flavour", "should be '"+FlavourMinimal+"' or '"+FlavourFull+"'")
+	}
+	if this.Source != nil && len(this.Source.Mirror) > 0 {
+		_, err := NewMirror(this.Source.Mirror)
+		if err != nil {
+			return configError("source.mirror", err.Error())
+		}
+	}
+	for key, dir := range map[string]string{"baseDir": this.BaseDir, "dataDir": this.DataDir, "tmpDir": this.TmpDir} {
+		if len(dir) > 0 && !filepath.IsAbs(dir) {
+			return configError(key, "should be an absolute path")
+		}
+	}
+	if len(this.Instance) > 0 {
+		err := ValidateInstanceName(this.Instance)
+		if err != nil {
+			return configError("instance", err.Error())
+		}
+	}
+	if this.Port != 0 && (this.Port < 1 || this.Port > 65535) {
+		return configError("port", "should be between 1 and 65535")
+	}
+	if len(this.StartTimeout) > 0 {
+		timeout, err := time.ParseDuration(this.StartTimeout)
+		if err != nil || timeout <= 0 {
+			return configError("startTimeout", "should be a positive duration, such as '5m'")
+		}
+	}
+	if this.RootPassword != nil {
+		if strings.ContainsAny(this.RootPassword.Password, "\r\n\x00") {
+			return configError("rootPassword.password", "should not contain line breaks")
+		}
+		if this.RootPassword.Length != 0 && (this.RootPassword.Length < 8 || this.RootPassword.Length > 64) {
+			return configError("rootPassword.length", "should be between 8 and 64")
+		}
+	}
+
+	var databaseNames = []string{}
+	for index, database := range this.Databases {
+		var prefix = "databases[" + strconv.Itoa(index) + "]"
+		if !databaseNameReg.MatchString(database.Name) {
+			return configError(prefix+".name", "invalid database name '"+database.Name+"'")
+		}
+		if containsString(databaseNames, database.Name) {
+			return configError(prefix+".name", "duplicate database '"+database.Name+"'")
+		}
+		databaseNames = append(databaseNames, database.Name)
+		if len(database.Charset) > 0 && !charsetNameReg.MatchString(database.Charset) {
+			return configError(prefix+".charset", "invalid charset '"+database.Charset+"'")
+		}
+		if len(database.Collation) > 0 && !charsetNameReg.MatchString(database.Collation) {
+			return configError(prefix+".collation", "invalid collation '"+database.Collation+"'")
+		}
+	}
+
+	var userNames = []string{}
+	for index, user := range this.Users {
+		var prefix = "users[" + strconv.Itoa(index) + "]"
+		if !userNameReg.MatchString(user.Name) {
+			return configError(prefix+".name", "invalid user name '"+user.Name+"'")
+		}
+		if len(user.Host) == 0 {
+			user.Host = DefaultUserHost
+		}
+		if !userHostReg.MatchString(user.Host) {
+			return configError(prefix+".host", "invalid host '"+user.Host+"'")
+		}
+		if user.Name == "root" {
+			return configError(prefix+".name", "user 'root' is managed by 'rootPassword'")
+		}
+		var fullName = user.Name + "@" + user.Host
+		if containsString(userNames, fullName) {
+			return configError(prefix, "duplicate user '"+fullName+"'")
+		}
+		userNames = append(userNames, fullName)
+		if strings.ContainsAny(user.Password, "\r\n\x00") {
+			return configError(prefix+".password", "should not contain line breaks")
+		}
+		for grantIndex, grant := range user.Grants {
+			var grantPrefix = prefix + ".grants[" + strconv.Itoa(grantIndex) + "]"
+			if grant.Database != "*" && !databaseNameReg.MatchString(grant.Database) {
+				return configError(grantPrefix+".database", "invalid database name '"+grant.Database+"'")
+			}
+			for privilegeIndex, privilege := range grant.Privileges {
+				if !privilegeReg.MatchString(privilege) {
+					return configError(grantPrefix+".privileges["+strconv.Itoa(privilegeIndex)+"]", "invalid privilege '"+privilege+"'")
+				}
+			}
+		}
+	}
+
+	for key, value := range this.MyCnf {
+		if !myCnfKeyReg.MatchString(key) {
+			return configError("myCnf."+key, "invalid option name")
+		}
+		var optionName = strings.ReplaceAll(strings.ToLower(key), "-", "_")
+		replacement, ok := reservedMyCnfKeys[optionName]
+		if ok {
+			return configError("myCnf."+key, "option is managed by installer, use '"+replacement+"' instead")
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return configError("myCnf."+key, "should not contain line breaks")
+		}
+	}
+	mysqlxPortKey, mysqlxPort, err := parseMysqlxPortOption(this.MyCnf)
+	if err != nil {
+		return configError("myCnf."+mysqlxPortKey, err.Error())
+	}
+	if len(mysqlxPortKey) > 0 && (mysqlxPort == this.Port || (this.Port == 0 && mysqlxPort == DefaultPort)) {
+		return configError("myCnf."+mysqlxPortKey, "should be different from 'port'")
+	}
+
+	if this.Service != nil && len(this.Service.Restart) > 0 {
+		switch this.Service.Restart {
+		case "no", "always", "on-success", "on-failure", "on-abnormal", "on-abort", "on-watchdog":
+		default:
+			return configError("service.restart", "invalid restart policy '"+this.Service.Restart+"'")
+		}
+	}
+
+	if this.Hardening != nil && this.Hardening.RootAuthSocket && !this.Hardening.IsEnabled() {
+		return configError("hardening.rootAuthSocket", "requires hardening to be enabled")
+	}
+
+	switch this.Dependencies {
+	case "", DependenciesInstall, DependenciesCheck, DependenciesSkip:
+	default:
+		return configError("dependencies", "should be '"+DependenciesInstall+"', '"+DependenciesCheck+"' or '"+DependenciesSkip+"'")
+	}
+
+	return nil
+}
+
+// check value against schema, and convert scalar values in maps to strings
+func normalizeConfigValue(path string, value interface{}, schema *configSchema) (interface{}, error) {
+	switch schema.kind {
+	case configKindString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, configError(path, "should be a string")
+		}
+		return s, nil
+	case configKindInt:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case json.Number:
+			i, err := v.Int64()
+			if err == nil {
+				return i, nil
+			}
+		}
+		return nil, configError(path, "should be an integer")
+	case configKindBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, configError(path, "should be true or false")
+		}
+		return b, nil
+	case configKindScalar:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case int, float64, bool, json.Number:
+			return fmt.Sprintf("%v", v), nil
+		}
+		return nil, configError(path, "should be a string, number or bool")
+	case configKindObject:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, configError(path, "should be an object")
+		}
+		var result = map[string]interface{}{}
+		for _, key := range sortedConfigKeys(m) {
+			var fieldPath = joinConfigPath(path, key)
+			fieldSchema, ok := schema.fields[key]
+			if !ok {
+				return nil, configError(fieldPath, "unknown key")
+			}
+			if m[key] == nil {
+				continue
+			}
+			fieldValue, err := normalizeConfigValue(fieldPath, m[key], fieldSchema)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = fieldValue
+		}
+		return result, nil
+	case configKindList:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, configError(path, "should be a list")
+		}
+		var result = []interface{}{}
+		for index, item := range list {
+			itemValue, err := normalizeConfigValue(path+"["+strconv.Itoa(index)+"]", item, schema.item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, itemValue)
+		}
+		return result, nil
+	case configKindMap:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, configError(path, "should be an object")
+		}
+		var result = map[string]interface{}{}
+		for _, key := range sortedConfigKeys(m) {
+			itemValue, err := normalizeConfigValue(joinConfigPath(path, key), m[key], schema.item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = itemValue
+		}
+		return result, nil
+	}
+	return nil, configError(path, "unknown kind '"+schema.kind+"'")
+}
+
+func sortedConfigKeys(m map[string]interface{}) []string {
+	var keys = []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinConfigPath(path string, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+func configError(path string, message string) error {
+	if len(path) == 0 {
+		return errors.New("invalid config: " + message)
+	}
+	return errors.New("invalid config '" + path + "': " + message)
+}
+
+// parse 'mysqlx_port' or 'mysqlx-port' in my.cnf options, quotes around value are trimmed
+// key is empty if the option is not set
+func parseMysqlxPortOption(options map[string]string) (key string, port int, err error) {
+	for optionKey, value := range options {
+		if strings.ReplaceAll(strings.ToLower(optionKey), "-", "_") != "mysqlx_port" {
+			continue
+		}
+		port, err = strconv.Atoi(strings.Trim(strings.TrimSpace(value), "\"'"))
+		if err != nil || port < 1 || port > 65535 {
+			return optionKey, 0, errors.New("should be between 1 and 65535")
+		}
+		return optionKey, port, nil
+	}
+	return "", 0, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"foolishmysql/internal/installers"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseInstallConfig(t *testing.T) {
+	var data = `
+version: "8.0.36"
+flavour: full
+source:
+  archive: mysql-8.0.36-linux-glibc2.17-x86_64.tar.xz
+  verifySignature: true
+baseDir: /opt/mysql
+port: 3307
+rootPassword:
+  length: 24
+databases:
+  - name: app
+    charset: utf8mb4
+users:
+  - name: app
+    host: "%"
+    grants:
+      - database: app
+        privileges: [SELECT, INSERT]
+myCnf:
+  max_connections: 1024
+  skip-name-resolve: true
+service:
+  enabled: false
+dependencies: check
+`
+	config, err := installers.ParseInstallConfig([]byte(data), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Version != "8.0.36" || config.Flavour != installers.FlavourFull || config.Port != 3307 || config.BaseDir != "/opt/mysql" {
+		t.Fatal("unexpected config:", config)
+	}
+	if config.RootPassword.Length != 24 || len(config.Databases) != 1 || len(config.Users) != 1 || config.Users[0].Grants[0].Privileges[1] != "INSERT" {
+		t.Fatal("unexpected config:", config)
+	}
+	if config.MyCnf["max_connections"] != "1024" || config.MyCnf["skip-name-resolve"] != "true" {
+		t.Fatal("unexpected my.cnf options:", config.MyCnf)
+	}
+	if config.Service.IsEnabled() || config.Dependencies != installers.DependenciesCheck {
+		t.Fatal("unexpected service and dependencies:", config.Service, config.Dependencies)
+	}
+
+	// json
+	config, err = installers.ParseInstallConfig([]byte(`{"port": 3308, "users": [{"name": "app"}]}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Port != 3308 || config.Users[0].Host != installers.DefaultUserHost || !config.Service.IsEnabled() {
+		t.Fatal("unexpected config:", config)
+	}
+}
+
+func TestParseInstallConfig_Invalid(t *testing.T) {
+	for data, key := range map[string]string{
+		`prot: 3306`:                                        "'prot'",
+		`port: "3306"`:                                      "'port'",
+		`port: 70000`:                                       "'port'",
+		`startTimeout: 10`:                                  "'startTimeout'",
+		`version: latest`:                                   "'version'",
+		`source: {archive: 1}`:                              "'source.archive'",
+		`users: [{name: app, host: "a b"}]`:                 "'users[0].host'",
+		`users: [{name: app, grants: [{db: app}]}]`:         "'users[0].grants[0].db'",
+		`databases: [{name: app}, {name: "app"}]`:           "'databases[1].name'",
+		`myCnf: {datadir: /data}`:                           "'myCnf.datadir'",
+		`myCnf: {max_connections: [1]}`:                     "'myCnf.max_connections'",
+		`myCnf: {mysqlx_port: 70000}`:                       "'myCnf.mysqlx_port'",
+		`{port: 3307, myCnf: {mysqlx-port: 3307}}`:          "'myCnf.mysqlx-port'",
+		`{port: 3307, myCnf: {mysqlx_port: "'3307'"}}`:      "'myCnf.mysqlx_port'",
+		`service: {restart: sometimes}`:                     "'service.restart'",
+		`dependencies: ignore`:                              "'dependencies'",
+		`hardening: {enabled: false, rootAuthSocket: true}`: "'hardening.rootAuthSocket'",
+		`rootPassword: {length: 4}`:                         "'rootPassword.length'",
+	} {
+		_, err := installers.ParseInstallConfig([]byte(data), false)
+		if err == nil {
+			t.Fatal("'" + data + "' should be invalid")
+		}
+		if !strings.Contains(err.Error(), key) {
+			t.Fatal("error of '"+data+"' should point at "+key+", but got:", err)
+		}
+		t.Log(err)
+	}
+}
+
+func TestParseInstallConfig_MysqlxPort(t *testing.T) {
+	// quoted value is accepted like mysqld does
+	_, err := installers.ParseInstallConfig([]byte(`{port: 3307, myCnf: {mysqlx_port: "'33070'"}}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadInstallConfig(t *testing.T) {
+	var dir = t.TempDir()
+	err := os.WriteFile(dir+"/install.json", []byte(`{"source": {"archive": "mysql.tar.xz"}}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := installers.LoadInstallConfig(dir + "/install.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Source.Archive != dir+"/mysql.tar.xz" {
+		t.Fatal("archive should be relative to config file, but got:", config.Source.Archive)
+	}
+}
+
+func TestParseDatabaseSpec(t *testing.T) {
+	database, err := installers.ParseDatabaseSpec("app:utf8mb4:utf8mb4_general_ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if database.Name != "app" || database.Charset != "utf8mb4" || database.Collation != "utf8mb4_general_ci" {
+		t.Fatal("unexpected database:", database)
+	}
+
+	for _, spec := range []string{"", ":utf8mb4", "app:utf8mb4:utf8mb4_bin:x"} {
+		_, err = installers.ParseDatabaseSpec(spec)
+		if err == nil {
+			t.Fatal("'" + spec + "' should be invalid")
+		}
+	}
+}
+
+func TestParseUserSpec(t *testing.T) {
+	user, err := installers.ParseUserSpec("app@192.168.1.0/255.255.255.0=app:SELECT+INSERT,*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "app" || user.Host != "192.168.1.0/255.255.255.0" || len(user.Password) > 0 || len(user.Grants) != 2 {
+		t.Fatal("unexpected user:", user)
+	}
+	if user.Grants[0].Database != "app" || strings.Join(user.Grants[0].Privileges, ",") != "SELECT,INSERT" || user.Grants[1].Database != "*" || len(user.Grants[1].Privileges) != 0 {
+		t.Fatal("unexpected grants:", user.Grants[0], user.Grants[1])
+	}
+
+	// host is filled with default value when validating
+	user, err = installers.ParseUserSpec("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "app" || len(user.Host) > 0 || len(user.Grants) != 0 {
+		t.Fatal("unexpected user:", user)
+	}
+
+	// password from environment variable or file
+	t.Setenv("TEST_APP_PASSWORD", "p'a:ss=1")
+	user, err = installers.ParseUserSpec("app@%=app:SELECT:env=TEST_APP_PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "app" || user.Host != "%" || user.Password != "p'a:ss=1" || len(user.Grants) != 1 || strings.Join(user.Grants[0].Privileges, ",") != "SELECT" {
+		t.Fatal("unexpected user:", user, user.Password)
+	}
+	var passwordFile = t.TempDir() + "/a=b:c.txt"
+	err = os.WriteFile(passwordFile, []byte("123456\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err = installers.ParseUserSpec("app:file=" + passwordFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "app" || user.Password != "123456" {
+		t.Fatal("unexpected user:", user, user.Password)
+	}
+
+	for _, spec := range []string{"", "@%", "app=", "app=app:", "app=,app", "app:env=", "app:env=TEST_NOT_EXIST_PASSWORD", "app:file=/not/exist"} {
+		_, err = installers.ParseUserSpec(spec)
+		if err == nil {
+			t.Fatal("'" + spec + "' should be invalid")
+		}
+	}
+}
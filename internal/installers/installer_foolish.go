@@ -3,529 +3,484 @@
 package installers
 
 import (
-	"bytes"
+	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
 	"foolishmysql/internal/utils"
-	"io"
-	"log"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
+const (
+	DefaultPort   = 3306
+	DefaultSocket = "/tmp/mysql.sock"
+
+	DefaultMysqlxPort = 33060 // X Plugin port of the default instance
+)
+
+var archiveGlibcReg = regexp.MustCompile(`-glibc(\d+\.\d+)-`)
+var archiveVersionReg = regexp.MustCompile(`^mysql-(\d+\.\d+\.\d+)-`)
+var instanceNameReg = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]{0,31}$`)
+
+// ValidateInstanceName check instance name, only letters, digits, '_', '-' and '.' are allowed, because it is used in file paths and service names
+func ValidateInstanceName(instance string) error {
+	if !instanceNameReg.MatchString(instance) {
+		return errors.New("invalid instance name '" + instance + "', only letters, digits, '_', '-' and '.' are allowed, and it should not start with '.'")
+	}
+	return nil
+}
+
+type Flavour = string
+
+const (
+	FlavourMinimal Flavour = "minimal" // stripped binaries without debug binaries and test suites
+	FlavourFull    Flavour = "full"    // full package including debug binaries and test suites
+)
+
 type FoolishInstaller struct {
 	password string
-}
 
-func NewFoolishInstaller() *FoolishInstaller {
-	return &FoolishInstaller{}
+	flavour Flavour
+	version string // version selector, such as '8.0.36', or '8.4' for latest release of the series
+	mirror  *Mirror
+	proxy   string // proxy url, default is from HTTP_PROXY and HTTPS_PROXY environment variables
+	cache   *Cache // cache of downloaded packages, packages will be downloaded into current dir if cache is nil
+	arch    string // x86_64 or aarch64, default is detected from current host
+	glibc   string // glibc version of host, default is detected from current host
+
+	dataDir string // data dir, default is '${baseDir}/data'
+	tmpDir  string // tmpdir option of mysqld, default is system temporary dir
+
+	instance string // instance name, used to run multiple mysql servers on one host
+	port     int
+	socket   string
+
+	md5Sum          string // expected md5 checksum of archive
+	sha256Sum       string // expected sha256 checksum of archive
+	verifySignature bool
+	skipChecksum    bool   // install downloaded archive even if no published checksum is found
+	signatureFile   string // detached signature file, default is '${archive}.asc'
+	publicKeyFile   string // public key to verify signature, default is the mysql release key
+
+	dryRun bool  // only check and plan changes without mutating the system
+	plan   *Plan // planned changes in dry-run mode
+
+	journal    *Journal // undo journal of current installation
+	rolledBack []string // rolled back steps after installation failed
+
+	startTimeout time.Duration // time to wait for a new server to accept connections
+
+	rootPassword     string            // fixed root password, a random one will be generated if empty
+	passwordLength   int               // length of generated passwords
+	myCnfOptions     map[string]string // extra options of [mysqld] section in my.cnf
+	serviceRestart   string            // Restart= option of service unit
+	dependencyPolicy DependencyPolicy
+	databases        []*DatabaseConfig // databases to create after installation
+	users            []*UserConfig     // users to create after installation
+	hardening        bool              // remove anonymous accounts, test database and remote root after root password is set
+	rootAuthSocket   bool              // authenticate root@localhost with auth_socket plugin after hardening
+	hardeningChanges []string          // changes made by hardening
+
+	reporter Reporter        // receiver of messages and events
+	ctx      context.Context // cancel downloading and installation
+
+	state     *InstallState
+	stateDir  string     // dir to store installation state files
+	resume    bool       // skip completed steps in state file
+	onlySteps []StepName // only run these steps
+	skipSteps []StepName // steps to bypass
 }
 
-func (this *FoolishInstaller) InstallFromFile(xzFilePath string, targetDir string) error {
-	// check whether mysql already running
-	this.log("checking mysqld ...")
-	var oldPid = utils.FindPidWithName("mysqld")
-	if oldPid > 0 {
-		return errors.New("there is already a running mysql server process, pid: '" + strconv.Itoa(oldPid) + "'")
-	}
+// InstallerOption option of NewFoolishInstaller()
+type InstallerOption func(installer *FoolishInstaller)
 
-	// check target dir
-	this.log("checking target dir '" + targetDir + "' ...")
-	_, err := os.Stat(targetDir)
-	if err == nil {
-		// check target dir
-		matches, _ := filepath.Glob(targetDir + "/*")
-		if len(matches) > 0 {
-			return errors.New("target dir '" + targetDir + "' already exists and not empty")
-		} else {
-			err = os.Remove(targetDir)
-			if err != nil {
-				return errors.New("clean target dir '" + targetDir + "' failed: " + err.Error())
-			}
+// WithReporter replace the default console reporter
+func WithReporter(reporter Reporter) InstallerOption {
+	return func(installer *FoolishInstaller) {
+		if reporter != nil {
+			installer.reporter = reporter
 		}
 	}
+}
 
-	// check 'tar' command
-	this.log("checking 'tar' command ...")
-	var tarExe, _ = exec.LookPath("tar")
-	if len(tarExe) == 0 {
-		this.log("installing 'tar' command ...")
-		err = this.installTarCommand()
-		if err != nil {
-			this.log("WARN: failed to install 'tar' ...")
-		}
+func NewFoolishInstaller(options ...InstallerOption) *FoolishInstaller {
+	var installer = &FoolishInstaller{
+		flavour:          FlavourMinimal,
+		port:             DefaultPort,
+		stateDir:         DefaultStateDir,
+		passwordLength:   DefaultPasswordLength,
+		serviceRestart:   "on-failure",
+		dependencyPolicy: DependenciesInstall,
+		hardening:        true,
+		startTimeout:     DefaultStartTimeout,
+		reporter:         NewConsoleReporter(),
+		ctx:              context.Background(),
 	}
-
-	// check commands
-	this.log("checking system commands ...")
-	var cmdList = []string{"tar" /** again **/, "chown", "sh"}
-	for _, cmd := range cmdList {
-		cmdPath, err := exec.LookPath(cmd)
-		if err != nil || len(cmdPath) == 0 {
-			return errors.New("could not find '" + cmd + "' command in this system")
-		}
+	for _, option := range options {
+		option(installer)
 	}
+	return installer
+}
 
-	groupAddExe, err := this.lookupGroupAdd()
-	if err != nil {
-		return errors.New("could not find 'groupadd' command in this system")
-	}
+// WithInstance set instance name
+// every instance has its own config file '/etc/mysql-${instance}.cnf' and service 'mysqld@${instance}.service'
+func (this *FoolishInstaller) WithInstance(instance string) *FoolishInstaller {
+	this.instance = instance
+	return this
+}
 
-	userAddExe, err := this.lookupUserAdd()
-	if err != nil {
-		return errors.New("could not find 'useradd' command in this system")
-	}
-
-	// ubuntu apt
-	aptGetExe, err := exec.LookPath("apt-get")
-	if err == nil && len(aptGetExe) > 0 {
-		for _, lib := range []string{"libaio1", "libncurses5", "libnuma1"} {
-			this.log("checking " + lib + " ...")
-			var cmd = utils.NewCmd(aptGetExe, "-y", "install", lib)
-			cmd.WithStderr()
-			err = cmd.Run()
-			if err != nil {
-				// try apt
-				aptExe, aptErr := exec.LookPath("apt")
-				if aptErr == nil && len(aptExe) > 0 {
-					cmd = utils.NewCmd(aptExe, "-y", "install", lib)
-					cmd.WithStderr()
-					err = cmd.Run()
-				}
-
-				if err != nil {
-					if lib == "libnuma1" {
-						err = nil
-					} else {
-						return errors.New("install " + lib + " failed: " + cmd.Stderr())
-					}
-				}
-			}
-			time.Sleep(1 * time.Second)
-		}
-	} else { // yum
-		yumExe, err := exec.LookPath("yum")
-		if err == nil && len(yumExe) > 0 {
-			for _, lib := range []string{"libaio", "ncurses-libs", "ncurses-compat-libs", "numactl-libs"} {
-				var cmd = utils.NewCmd("yum", "-y", "install", lib)
-				_ = cmd.Run()
-				time.Sleep(1 * time.Second)
-			}
-		}
+// WithPort set server port
+func (this *FoolishInstaller) WithPort(port int) *FoolishInstaller {
+	this.port = port
+	return this
+}
 
-		// create symbolic links
-		{
-			var libFile = "/usr/lib64/libncurses.so.5"
-			_, err = os.Stat(libFile)
-			if err != nil && os.IsNotExist(err) {
-				var latestLibFile = utils.FindLatestVersionFile("/usr/lib64", "libncurses.so.")
-				if len(latestLibFile) > 0 {
-					this.log("link '" + latestLibFile + "' to '" + libFile + "'")
-					_ = os.Symlink(latestLibFile, libFile)
-				}
-			}
-		}
+// WithSocket set unix socket file
+func (this *FoolishInstaller) WithSocket(socket string) *FoolishInstaller {
+	this.socket = socket
+	return this
+}
 
-		{
-			var libFile = "/usr/lib64/libtinfo.so.5"
-			_, err = os.Stat(libFile)
-			if err != nil && os.IsNotExist(err) {
-				var latestLibFile = utils.FindLatestVersionFile("/usr/lib64", "libtinfo.so.")
-				if len(latestLibFile) > 0 {
-					this.log("link '" + latestLibFile + "' to '" + libFile + "'")
-					_ = os.Symlink(latestLibFile, libFile)
-				}
-			}
-		}
-	}
+// WithStartTimeout set time to wait for the started server to accept connections, default is DefaultStartTimeout
+func (this *FoolishInstaller) WithStartTimeout(timeout time.Duration) *FoolishInstaller {
+	this.startTimeout = timeout
+	return this
+}
 
-	// create 'mysql' user group
-	this.log("checking 'mysql' user group ...")
-	{
-		data, err := os.ReadFile("/etc/group")
-		if err != nil {
-			return errors.New("check user group failed: " + err.Error())
-		}
-		if !bytes.Contains(data, []byte("\nmysql:")) {
-			var cmd = utils.NewCmd(groupAddExe, "mysql")
-			cmd.WithStderr()
-			err = cmd.Run()
-			if err != nil {
-				return errors.New("add 'mysql' user group failed: " + cmd.Stderr())
-			}
-		}
-	}
+// WithDataDir set data dir, it can be outside of base dir
+func (this *FoolishInstaller) WithDataDir(dataDir string) *FoolishInstaller {
+	this.dataDir = dataDir
+	return this
+}
 
-	// create 'mysql' user
-	this.log("checking 'mysql' user ...")
-	{
-		data, err := os.ReadFile("/etc/passwd")
-		if err != nil {
-			return errors.New("check user failed: " + err.Error())
-		}
-		if !bytes.Contains(data, []byte("\nmysql:")) {
-			var cmd *utils.Cmd
-			if strings.HasSuffix(userAddExe, "useradd") {
-				cmd = utils.NewCmd(userAddExe, "mysql", "-g", "mysql")
-			} else { // adduser
-				cmd = utils.NewCmd(userAddExe, "-S", "-G", "mysql", "mysql")
-			}
-			cmd.WithStderr()
-			err = cmd.Run()
-			if err != nil {
-				return errors.New("add 'mysql' user failed: " + cmd.Stderr())
-			}
-		}
-	}
+// WithTmpDir set 'tmpdir' option of mysqld
+func (this *FoolishInstaller) WithTmpDir(tmpDir string) *FoolishInstaller {
+	this.tmpDir = tmpDir
+	return this
+}
 
-	// mkdir
-	{
-		var parentDir = filepath.Dir(targetDir)
-		stat, err := os.Stat(parentDir)
-		if err != nil {
-			if os.IsNotExist(err) {
-				err = os.MkdirAll(parentDir, 0777)
-				if err != nil {
-					return errors.New("try to create dir '" + parentDir + "' failed: " + err.Error())
-				}
-			} else {
-				return errors.New("check dir '" + parentDir + "' failed: " + err.Error())
-			}
-		} else {
-			if !stat.IsDir() {
-				return errors.New("'" + parentDir + "' should be a directory")
-			}
-		}
-	}
+// WithVersion set mysql version to download, such as '8.0.36', or '8.4' for latest release of the series
+func (this *FoolishInstaller) WithVersion(version string) *FoolishInstaller {
+	this.version = version
+	return this
+}
 
-	// check installer file .xz
-	this.log("checking installer file ...")
-	{
-		stat, err := os.Stat(xzFilePath)
-		if err != nil {
-			return errors.New("could not open the installer file: " + err.Error())
-		}
-		if stat.IsDir() {
-			return errors.New("'" + xzFilePath + "' not a valid file")
-		}
+// WithChecksums set expected checksums of archive file, empty checksum will be ignored
+func (this *FoolishInstaller) WithChecksums(md5Sum string, sha256Sum string) *FoolishInstaller {
+	this.md5Sum = md5Sum
+	this.sha256Sum = sha256Sum
+	return this
+}
 
-		var basename = filepath.Base(xzFilePath)
-		if !strings.HasSuffix(basename, ".xz") {
-			return errors.New("installer file should has '.xz' extension")
-		}
-	}
+// WithSignature enable GPG signature verification of archive file
+// signatureFile and publicKeyFile are optional
+func (this *FoolishInstaller) WithSignature(signatureFile string, publicKeyFile string) *FoolishInstaller {
+	this.verifySignature = true
+	this.signatureFile = signatureFile
+	this.publicKeyFile = publicKeyFile
+	return this
+}
 
-	// extract
-	this.log("extracting installer file ...")
-	var tmpDir = os.TempDir() + "/foolish-mysql-tmp"
-	{
-		_, err := os.Stat(tmpDir)
-		if err == nil {
-			err = os.RemoveAll(tmpDir)
-			if err != nil {
-				return errors.New("clean temporary directory '" + tmpDir + "' failed: " + err.Error())
-			}
-		}
-		err = os.Mkdir(tmpDir, 0777)
-		if err != nil {
-			return errors.New("create temporary directory '" + tmpDir + "' failed: " + err.Error())
-		}
-	}
+// WithSkipChecksum install downloaded archive even if no published checksum is found
+func (this *FoolishInstaller) WithSkipChecksum(skip bool) *FoolishInstaller {
+	this.skipChecksum = skip
+	return this
+}
 
-	{
-		var cmd = utils.NewCmd("tar", "-xJvf", xzFilePath, "-C", tmpDir)
-		cmd.WithStderr()
-		err = cmd.Run()
-		if err != nil {
-			return errors.New("extract installer file '" + xzFilePath + "' failed: " + cmd.Stderr())
-		}
-	}
+// WithMirror download packages from custom mirror instead of mysql CDN
+func (this *FoolishInstaller) WithMirror(mirror *Mirror) *FoolishInstaller {
+	this.mirror = mirror
+	return this
+}
 
-	// create datadir
-	matches, err := filepath.Glob(tmpDir + "/mysql-*")
-	if err != nil || len(matches) == 0 {
-		return errors.New("could not find mysql installer directory from '" + tmpDir + "'")
-	}
-	var baseDir = matches[0]
-	var dataDir = baseDir + "/data"
-	_, err = os.Stat(dataDir)
-	if err != nil {
-		if os.IsNotExist(err) {
-			err = os.Mkdir(dataDir, 0777)
-			if err != nil {
-				return errors.New("create data dir '" + dataDir + "' failed: " + err.Error())
-			}
-		} else {
-			return errors.New("check data dir '" + dataDir + "' failed: " + err.Error())
-		}
-	}
+// WithProxy set proxy url for downloading
+func (this *FoolishInstaller) WithProxy(proxy string) *FoolishInstaller {
+	this.proxy = proxy
+	return this
+}
 
-	// chown datadir
-	{
-		var cmd = utils.NewCmd("chown", "mysql:mysql", dataDir)
-		cmd.WithStderr()
-		err = cmd.Run()
-		if err != nil {
-			return errors.New("chown data dir '" + dataDir + "' failed: " + err.Error())
-		}
-	}
+// WithCache store downloaded packages in cache, and reuse them
+func (this *FoolishInstaller) WithCache(cache *Cache) *FoolishInstaller {
+	this.cache = cache
+	return this
+}
 
-	// create my.cnf
-	var myCnfFile = "/etc/my.cnf"
-	_, err = os.Stat(myCnfFile)
-	if err == nil {
-		// backup it
-		err = os.Rename(myCnfFile, "/etc/my.cnf."+utils.Format("YmdHis"))
-		if err != nil {
-			return errors.New("backup '/etc/my.cnf' failed: " + err.Error())
-		}
-	}
+// WithPlatform set architecture and glibc version of the target host instead of detecting them
+func (this *FoolishInstaller) WithPlatform(arch string, glibcVersion string) *FoolishInstaller {
+	this.arch = arch
+	this.glibc = glibcVersion
+	return this
+}
 
-	// mysql server options https://dev.mysql.com/doc/refman/8.0/en/server-system-variables.html
-	var myCnfTemplate = this.createMyCnf(baseDir, dataDir)
-	err = os.WriteFile(myCnfFile, []byte(myCnfTemplate), 0666)
-	if err != nil {
-		return errors.New("write '" + myCnfFile + "' failed: " + err.Error())
+// WithDryRun walk installation steps with read-only checks only, changes are recorded into Plan() instead of being made
+func (this *FoolishInstaller) WithDryRun(dryRun bool) *FoolishInstaller {
+	this.dryRun = dryRun
+	if dryRun {
+		this.plan = NewPlan()
+	} else {
+		this.plan = nil
 	}
+	return this
+}
 
-	// initialize
-	this.log("initializing mysql ...")
-	var generatedPassword = ""
-	{
-		var cmd = utils.NewCmd(baseDir+"/bin/mysqld", "--initialize", "--user=mysql")
-		cmd.WithStderr()
-		cmd.WithStdout()
-		err = cmd.Run()
-		if err != nil {
-			return errors.New("initialize failed: " + cmd.Stderr())
-		}
+// Plan get planned changes in dry-run mode
+func (this *FoolishInstaller) Plan() *Plan {
+	return this.plan
+}
 
-		// read from stdout
-		var match = regexp.MustCompile(`temporary password is.+:\s*(.+)`).FindStringSubmatch(cmd.Stdout())
-		if len(match) == 0 {
-			// read from stderr
-			match = regexp.MustCompile(`temporary password is.+:\s*(.+)`).FindStringSubmatch(cmd.Stderr())
+// WithSteps run only these steps if onlySteps is not empty, and bypass skipSteps
+func (this *FoolishInstaller) WithSteps(onlySteps []StepName, skipSteps []StepName) *FoolishInstaller {
+	this.onlySteps = onlySteps
+	this.skipSteps = skipSteps
+	return this
+}
 
-			if len(match) == 0 {
-				return errors.New("initialize successfully, but could not find generated password, please report to developer")
-			}
-		}
-		generatedPassword = strings.TrimSpace(match[1])
+// WithStateDir set dir to store installation state files
+func (this *FoolishInstaller) WithStateDir(stateDir string) *FoolishInstaller {
+	this.stateDir = stateDir
+	return this
+}
 
-		// write password to file
-		var passwordFile = baseDir + "/generated-password.txt"
-		err = os.WriteFile(passwordFile, []byte(generatedPassword+"\n"), 0666)
-		if err != nil {
-			return errors.New("write password failed: " + err.Error())
-		}
+// WithRootPassword set fixed root password, or length of generated one if password is empty
+func (this *FoolishInstaller) WithRootPassword(password string, length int) *FoolishInstaller {
+	this.rootPassword = password
+	if length > 0 {
+		this.passwordLength = length
 	}
+	return this
+}
 
-	// move to right place
-	this.log("moving files to target dir ...")
-	err = os.Rename(baseDir, targetDir)
-	if err != nil {
-		return errors.New("move '" + baseDir + "' to '" + targetDir + "' failed: " + err.Error())
+// WithMyCnfOptions set extra options of [mysqld] section in my.cnf, they override default options
+func (this *FoolishInstaller) WithMyCnfOptions(options map[string]string) *FoolishInstaller {
+	this.myCnfOptions = options
+	return this
+}
+
+// WithServiceRestart set Restart= option of systemd service
+func (this *FoolishInstaller) WithServiceRestart(restart string) *FoolishInstaller {
+	if len(restart) > 0 {
+		this.serviceRestart = restart
 	}
-	baseDir = targetDir
+	return this
+}
 
-	// change my.cnf
-	myCnfTemplate = this.createMyCnf(baseDir, baseDir+"/data")
-	err = os.WriteFile(myCnfFile, []byte(myCnfTemplate), 0666)
-	if err != nil {
-		return errors.New("create new '" + myCnfFile + "' failed: " + err.Error())
+// WithDependencyPolicy choose how to deal with shared libraries required by mysql
+func (this *FoolishInstaller) WithDependencyPolicy(policy DependencyPolicy) *FoolishInstaller {
+	if len(policy) > 0 {
+		this.dependencyPolicy = policy
 	}
+	return this
+}
 
-	// start mysql
-	this.log("starting mysql ...")
-	{
-		var cmd = utils.NewCmd(baseDir+"/bin/mysqld_safe", "--user=mysql")
-		cmd.WithStderr()
-		err = cmd.Start()
-		if err != nil {
-			return errors.New("start failed '" + cmd.String() + "': " + cmd.Stderr())
-		}
+// WithDatabases set databases to create after installation
+func (this *FoolishInstaller) WithDatabases(databases []*DatabaseConfig) *FoolishInstaller {
+	this.databases = databases
+	return this
+}
 
-		// waiting for startup
-		for i := 0; i < 30; i++ {
-			var conn net.Conn
-			conn, err = net.Dial("tcp", "127.0.0.1:3306")
-			if err != nil {
-				time.Sleep(1 * time.Second)
-			} else {
-				_ = conn.Close()
-				break
-			}
-		}
-		time.Sleep(1 * time.Second)
-	}
+// WithHardening enable or disable hardening after root password is set, it is enabled by default
+// root@localhost is switched to auth_socket plugin if rootAuthSocket is true, then it can only login as system user 'root' with unix socket
+func (this *FoolishInstaller) WithHardening(enabled bool, rootAuthSocket bool) *FoolishInstaller {
+	this.hardening = enabled
+	this.rootAuthSocket = enabled && rootAuthSocket
+	return this
+}
 
-	// change password
-	newPassword, err := this.generatePassword()
-	if err != nil {
-		return errors.New("generate new password failed: " + err.Error())
-	}
+// WithUsers set users to create after installation
+func (this *FoolishInstaller) WithUsers(users []*UserConfig) *FoolishInstaller {
+	this.users = users
+	return this
+}
 
-	this.log("changing mysql password ...")
-	var passwordSQL = "ALTER USER 'root'@'localhost' IDENTIFIED BY '" + newPassword + "';"
-	{
-		var cmd = utils.NewCmd("sh", "-c", baseDir+"/bin/mysql --host=\"127.0.0.1\"  --user=root --password=\""+generatedPassword+"\" --execute=\""+passwordSQL+"\" --connect-expired-password")
-		cmd.WithStderr()
-		err = cmd.Run()
-		if err != nil {
-			return errors.New("change password failed: " + cmd.String() + ": " + cmd.Stderr())
-		}
-	}
-	this.password = newPassword
-	var passwordFile = baseDir + "/generated-password.txt"
-	err = os.WriteFile(passwordFile, []byte(this.password), 0666)
-	if err != nil {
-		return errors.New("write generated file failed: " + err.Error())
+// WithContext set context to cancel downloading and installation
+// after canceled, the running step is stopped and changes of installation are rolled back
+func (this *FoolishInstaller) WithContext(ctx context.Context) *FoolishInstaller {
+	if ctx != nil {
+		this.ctx = ctx
 	}
+	return this
+}
 
-	// remove temporary directory
-	_ = os.Remove(tmpDir)
+// WithFlavour choose package flavour to download
+func (this *FoolishInstaller) WithFlavour(flavour Flavour) *FoolishInstaller {
+	this.flavour = flavour
+	return this
+}
 
-	// create link to 'mysql' client command
-	var clientExe = "/usr/local/bin/mysql"
-	_, err = os.Stat(clientExe)
-	if err != nil && os.IsNotExist(err) {
-		err = os.Symlink(baseDir+"/bin/mysql", clientExe)
-		if err == nil {
-			this.log("created symbolic link '" + clientExe + "' to '" + baseDir + "/bin/mysql'")
-		} else {
-			this.log("WARN: failed to create symbolic link '" + clientExe + "' to '" + baseDir + "/bin/mysql': " + err.Error())
-		}
+// InstallFromFile install mysql from archive file into target dir
+// changes made by this run will be rolled back if installation failed, see RolledBack()
+func (this *FoolishInstaller) InstallFromFile(archivePath string, targetDir string) error {
+	if len(this.instance) > 0 && len(this.socket) == 0 {
+		this.socket = "/tmp/mysql-" + this.instance + ".sock"
+	}
+
+	var state = &InstallState{
+		ArchivePath:   archivePath,
+		TargetDir:     targetDir,
+		Instance:      this.instance,
+		Port:          this.port,
+		Socket:        this.socket,
+		DataDirOption: this.dataDir,
+		TmpDirOption:  this.tmpDir,
+		BaseDir:       targetDir,
+
+		RootPasswordOption: this.rootPassword,
+		PasswordLength:     this.passwordLength,
+		MyCnfOptions:       this.myCnfOptions,
+		ServiceRestart:     this.serviceRestart,
+		DependencyPolicy:   this.dependencyPolicy,
+		Databases:          this.databases,
+		Users:              this.users,
+		NoHardening:        !this.hardening,
+		RootAuthSocket:     this.rootAuthSocket,
+	}
+	if len(this.dataDir) > 0 && filepath.Clean(this.dataDir) != filepath.Clean(targetDir+"/data") {
+		state.ExternalDataDir = this.dataDir
 	}
+	return this.install(state)
+}
 
-	// install service
-	// this is not required, so we ignore all errors
-	err = this.installService(baseDir)
+// Resume continue the installation from the last successful step in state file
+func (this *FoolishInstaller) Resume() error {
+	state, err := this.loadState()
 	if err != nil {
-		this.log("WARN: install service failed: " + err.Error())
+		return err
 	}
+	this.instance = state.Instance
+	this.port = state.Port
+	this.socket = state.Socket
+	this.dataDir = state.DataDirOption
+	this.tmpDir = state.TmpDirOption
+	this.password = state.RootPassword
+	this.rootPassword = state.RootPasswordOption
+	this.myCnfOptions = state.MyCnfOptions
+	this.databases = state.Databases
+	this.users = state.Users
+	this.WithRootPassword("", state.PasswordLength)
+	this.WithServiceRestart(state.ServiceRestart)
+	this.WithDependencyPolicy(state.DependencyPolicy)
+	this.WithHardening(!state.NoHardening, state.RootAuthSocket)
+	this.hardeningChanges = state.HardeningChanges
+	this.resume = true
+	this.log("resuming installation, completed steps: " + strings.Join(state.CompletedSteps, ", "))
+	return this.install(state)
+}
 
-	this.log("finished")
+// RolledBack get rolled back steps after installation failed
+func (this *FoolishInstaller) RolledBack() []string {
+	return this.rolledBack
+}
 
-	return nil
+// BaseDir get installed base dir
+func (this *FoolishInstaller) BaseDir() string {
+	if this.state == nil {
+		return ""
+	}
+	return this.state.BaseDir
 }
 
-func (this *FoolishInstaller) Download() (path string, err error) {
-	var client = &http.Client{}
+// DataDir get installed data dir
+func (this *FoolishInstaller) DataDir() string {
+	if this.state == nil {
+		return ""
+	}
+	return this.state.DataDir()
+}
 
-	// check latest version
-	this.log("checking mysql latest version ...")
-	var latestVersion = "8.2.0" // default version
-	var majorVersion = "8.2"
-	{
-		req, reqErr := http.NewRequest(http.MethodGet, "https://dev.mysql.com/downloads/mysql/", nil)
-		if reqErr != nil {
-			return "", reqErr
-		}
+// Users get users created after installation, with generated passwords
+func (this *FoolishInstaller) Users() []*UserConfig {
+	return this.users
+}
 
-		req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Ubuntu Chromium/78.0.3904.108 Chrome/78.0.3904.108 Safari/537.36")
-		resp, err := client.Do(req)
-		if err != nil {
-			return "", errors.New("check latest version failed: " + err.Error())
-		}
-		defer func() {
-			_ = resp.Body.Close()
-		}()
-
-		if resp.StatusCode == http.StatusOK {
-			data, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return "", errors.New("read latest version failed: " + err.Error())
-			}
+// InstallResult summary of a successful installation
+type InstallResult struct {
+	Version        string   `json:"version"`
+	BaseDir        string   `json:"baseDir"`
+	DataDir        string   `json:"dataDir"`
+	Port           int      `json:"port"`
+	Socket         string   `json:"socket"`
+	ConfigFile     string   `json:"configFile"`
+	ServiceName    string   `json:"serviceName"`
+	CredentialFile string   `json:"credentialFile,omitempty"` // file containing root password, empty if root authenticates with auth_socket
+	RootAuthSocket bool     `json:"rootAuthSocket,omitempty"` // root@localhost authenticates with auth_socket plugin
+	Hardening      []string `json:"hardening,omitempty"`      // changes made by hardening
+}
 
-			var reg = regexp.MustCompile(`<h1>MySQL Community Server ([\d.]+) `)
-			var matches = reg.FindSubmatch(data)
-			if len(matches) > 0 {
-				latestVersion = string(matches[1])
-				var matchPieces = strings.Split(latestVersion, ".")
-				if len(matchPieces) >= 2 {
-					majorVersion = strings.Join(matchPieces[:2], ".")
-				}
-			}
+// Result get summary of installation
+func (this *FoolishInstaller) Result() *InstallResult {
+	var result = &InstallResult{
+		BaseDir:     this.BaseDir(),
+		DataDir:     this.DataDir(),
+		Port:        this.port,
+		Socket:      this.socketFile(),
+		ConfigFile:  this.ConfigFile(),
+		ServiceName: this.ServiceName(),
+		Hardening:   this.hardeningChanges,
+	}
+	if this.state != nil {
+		result.Version = this.state.Version
+		result.RootAuthSocket = this.isRootAuthSocket()
+		if !result.RootAuthSocket {
+			result.CredentialFile = this.credentialFile(this.state.BaseDir)
 		}
 	}
-	this.log("found version: v" + latestVersion)
-
-	// download
-	this.log("start downloading ...")
+	return result
+}
 
-	var downloadURL = "https://cdn.mysql.com/Downloads/MySQL-" + majorVersion + "/mysql-" + latestVersion + "-linux-glibc2.17-x86_64-minimal.tar.xz"
+// unix socket file of server
+func (this *FoolishInstaller) socketFile() string {
+	if len(this.socket) > 0 {
+		return this.socket
+	}
+	return DefaultSocket
+}
 
-	{
-		this.log("downloading from url '" + downloadURL + "' ...")
-		req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
-		if err != nil {
-			return "", err
-		}
+// check whether root@localhost has been switched to auth_socket
+func (this *FoolishInstaller) isRootAuthSocket() bool {
+	return this.rootAuthSocket && this.state != nil && this.state.IsCompleted(StepHarden)
+}
 
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/105.0.0.0 Safari/537.36")
-		resp, err := client.Do(req)
-		if err != nil {
-			return "", errors.New("check latest version failed: " + err.Error())
-		}
-		defer func() {
-			_ = resp.Body.Close()
-		}()
+// file to keep generated root password
+func (this *FoolishInstaller) credentialFile(baseDir string) string {
+	return baseDir + "/generated-password.txt"
+}
 
-		if resp.StatusCode != http.StatusOK {
-			return "", errors.New("check latest version failed: invalid response code: " + strconv.Itoa(resp.StatusCode))
-		}
+// Port get server port
+func (this *FoolishInstaller) Port() int {
+	return this.port
+}
 
-		path = filepath.Base(downloadURL)
-		fp, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
-		if err != nil {
-			return "", errors.New("create download file '" + path + "' failed: " + err.Error())
-		}
-		var writer = utils.NewProgressWriter(fp, resp.ContentLength)
-		var ticker = time.NewTicker(1 * time.Second)
-		var done = make(chan bool, 1)
-		go func() {
-			var lastProgress float32 = -1
-
-			for {
-				select {
-				case <-ticker.C:
-					var progress = writer.Progress()
-					if lastProgress < 0 || progress-lastProgress > 0.1 || progress == 1 {
-						lastProgress = progress
-						this.log(fmt.Sprintf("%.2f%%", progress*100))
-					}
-				case <-done:
-					return
-				}
-			}
-		}()
-		_, err = io.Copy(writer, resp.Body)
-		if err != nil {
-			_ = fp.Close()
-			done <- true
-			return "", errors.New("download failed: " + err.Error())
-		}
+func (this *FoolishInstaller) install(state *InstallState) error {
+	this.state = state
+	this.journal = nil
+	this.rolledBack = nil
+	if !this.dryRun {
+		this.journal = NewJournal()
+	}
 
-		err = fp.Close()
-		if err != nil {
-			done <- true
-			return "", errors.New("download failed: " + err.Error())
-		}
+	err := this.runSteps(state)
+	this.journal = nil
+	if err != nil {
+		return err
+	}
 
-		time.Sleep(1 * time.Second) // waiting for progress printing
-		done <- true
+	// state is not needed any more after all steps were run
+	if !this.dryRun && len(this.onlySteps) == 0 {
+		_ = os.Remove(this.StateFile())
 	}
 
-	return path, nil
+	this.log("finished")
+	return nil
 }
 
 // Password get generated password
@@ -544,11 +499,36 @@ func (this *FoolishInstaller) createMyCnf(baseDir string, dataDir string) string
 		}
 	}
 
-	return `
+	var extraOptions = ""
+	var clientSection = ""
+
+	if len(this.tmpDir) > 0 {
+		extraOptions += "\ntmpdir=\"" + this.tmpDir + "\""
+	}
+
+	// avoid conflicts of X Plugin between instances
+	if len(this.instance) > 0 {
+		var mysqlxPort = this.mysqlxPort()
+		if mysqlxPort > 0 {
+			extraOptions += "\nmysqlx_port=" + strconv.Itoa(mysqlxPort) + "\nmysqlx_socket=\"/tmp/mysqlx-" + this.instance + ".sock\""
+		} else {
+			extraOptions += "\nmysqlx=OFF"
+		}
+	}
+	if len(this.socket) > 0 {
+		extraOptions += "\nsocket=\"" + this.socket + "\""
+		clientSection = `
+
+[client]
+port=` + strconv.Itoa(this.port) + `
+socket="` + this.socket + `"`
+	}
+
+	var mysqldSection = `
 [mysqld]
-port=3306
+port=` + strconv.Itoa(this.port) + `
 basedir="` + baseDir + `"
-datadir="` + dataDir + `"
+datadir="` + dataDir + `"` + extraOptions + `
 
 max_connections=256
 innodb_flush_log_at_trx_commit=2
@@ -559,25 +539,150 @@ thread_cache_size=32
 binlog_expire_logs_seconds=604800
 innodb_sort_buffer_size=8M
 innodb_buffer_pool_size=` + strconv.Itoa(memoryTotalG) + "G"
+
+	return this.overrideMyCnfOptions(mysqldSection) + clientSection
+}
+
+// X Plugin port of instance, 0 if it can not be derived
+// port set in my.cnf options is used first, otherwise it is derived as 'port * 10' like the default 3306 and 33060
+func (this *FoolishInstaller) mysqlxPort() int {
+	key, port, err := parseMysqlxPortOption(this.myCnfOptions)
+	if err == nil && len(key) > 0 {
+		return port
+	}
+
+	// default X Plugin port is kept for the default instance
+	port = this.port * 10
+	if port > 65535 || port == DefaultMysqlxPort {
+		return 0
+	}
+	return port
+}
+
+// replace options in my.cnf section with extra options, or append them
+func (this *FoolishInstaller) overrideMyCnfOptions(section string) string {
+	if len(this.myCnfOptions) == 0 {
+		return section
+	}
+	var keys = []string{}
+	for key := range this.myCnfOptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var lines = strings.Split(section, "\n")
+	for _, key := range keys {
+		var line = key + "=" + this.myCnfOptions[key]
+		var optionName = strings.ReplaceAll(key, "-", "_")
+		var found = false
+		for index, oldLine := range lines {
+			var pieces = strings.SplitN(oldLine, "=", 2)
+			if len(pieces) == 2 && strings.ReplaceAll(strings.TrimSpace(pieces[0]), "-", "_") == optionName {
+				lines[index] = line
+				found = true
+				break
+			}
+		}
+		if !found {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ConfigFile get my.cnf file path
+func (this *FoolishInstaller) ConfigFile() string {
+	if len(this.instance) > 0 {
+		return "/etc/mysql-" + this.instance + ".cnf"
+	}
+	return "/etc/my.cnf"
+}
+
+// ServiceName get systemd service name
+func (this *FoolishInstaller) ServiceName() string {
+	if len(this.instance) > 0 {
+		return "mysqld@" + this.instance + ".service"
+	}
+	return "mysqld.service"
+}
+
+// check other mysql servers using the same port, socket or data dir
+func (this *FoolishInstaller) checkConflicts(dataDir string) error {
+	absDataDir, err := filepath.Abs(dataDir)
+	if err == nil {
+		dataDir = absDataDir
+	}
+	realDataDir, err := filepath.EvalSymlinks(dataDir)
+	if err == nil {
+		dataDir = realDataDir
+	}
+
+	// mysqld changes its working directory to data dir
+	for _, pid := range utils.FindPidsWithName("mysqld") {
+		var cwd = utils.ProcessCwd(pid)
+		if len(cwd) > 0 && filepath.Clean(cwd) == filepath.Clean(dataDir) {
+			return errors.New("data dir '" + dataDir + "' is being used by a running mysql server process, pid: '" + strconv.Itoa(pid) + "'")
+		}
+	}
+
+	// port
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(this.port))
+	if err != nil {
+		return errors.New("port '" + strconv.Itoa(this.port) + "' is being used by another process: " + err.Error())
+	}
+	_ = listener.Close()
+
+	// socket
+	if len(this.socket) > 0 {
+		conn, err := net.DialTimeout("unix", this.socket, 1*time.Second)
+		if err == nil {
+			_ = conn.Close()
+			return errors.New("socket '" + this.socket + "' is being used by another process")
+		}
+	}
+
+	return nil
 }
 
 // generate random password
 func (this *FoolishInstaller) generatePassword() (string, error) {
-	var p = make([]byte, 16)
+	var length = this.passwordLength
+	if length <= 0 {
+		length = DefaultPasswordLength
+	}
+	var p = make([]byte, (length+1)/2)
 	n, err := rand.Read(p)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%x", p[:n]), nil
+	return fmt.Sprintf("%x", p[:n])[:length], nil
+}
+
+// create command which is killed after context is canceled
+func (this *FoolishInstaller) command(name string, args ...string) *utils.Cmd {
+	return utils.NewCmd(name, args...).WithContext(this.ctx)
+}
+
+// sleep until context is canceled
+func (this *FoolishInstaller) sleep(duration time.Duration) error {
+	var timer = time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-this.ctx.Done():
+		return this.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // print log
 func (this *FoolishInstaller) log(message string) {
-	_, b := os.LookupEnv("QUIET")
-	if b {
-		return
-	}
-	log.Println(message)
+	this.reporter.Log(message)
+}
+
+// print warning
+func (this *FoolishInstaller) warn(message string) {
+	this.reporter.Warning(message)
 }
 
 // copy file
@@ -589,6 +694,10 @@ func (this *FoolishInstaller) installService(baseDir string) error {
 
 	this.log("registering systemd service ...")
 
+	if len(this.instance) > 0 {
+		return this.installInstanceService(baseDir)
+	}
+
 	var startCmd = "${BASE_DIR}/support-files/mysql.server start"
 	bashPath, _ := exec.LookPath("bash")
 	if len(bashPath) > 0 {
@@ -613,7 +722,7 @@ After=network-online.target
 
 [Service]
 Type=simple
-Restart=on-failure
+Restart=${RESTART}
 RestartSec=5s
 RemainAfterExit=yes
 ExecStart=` + startCmd + `
@@ -626,54 +735,69 @@ ExecReload=${BASE_DIR}/support-files/mysql.server reload
 WantedBy=multi-user.target`
 
 	desc = strings.ReplaceAll(desc, "${BASE_DIR}", baseDir)
+	desc = strings.ReplaceAll(desc, "${RESTART}", this.serviceRestart)
+
+	return this.enableService(desc)
+}
+
+// install service for instance, run mysqld directly with its own config file
+func (this *FoolishInstaller) installInstanceService(baseDir string) error {
+	var desc = `[Unit]
+Description=MySQL Service (` + this.instance + `)
+Before=shutdown.target
+After=network-online.target
+
+[Service]
+Type=simple
+User=mysql
+Group=mysql
+Restart=` + this.serviceRestart + `
+RestartSec=5s
+LimitNOFILE=65535
+ExecStart=` + baseDir + `/bin/mysqld --defaults-file=` + this.ConfigFile() + `
+
+[Install]
+WantedBy=multi-user.target`
+
+	return this.enableService(desc)
+}
 
-	err = os.WriteFile("/etc/systemd/system/mysqld.service", []byte(desc), 0666)
+// write service file and enable it
+func (this *FoolishInstaller) enableService(desc string) error {
+	var serviceName = this.ServiceName()
+	err := this.writeFile("/etc/systemd/system/"+serviceName, []byte(desc), 0666)
 	if err != nil {
 		return err
 	}
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionService, Name: serviceName, Command: []string{"systemctl", "enable", serviceName}})
+		return nil
+	}
 
-	var cmd = utils.NewTimeoutCmd(5*time.Second, "systemctl", "enable", "mysqld.service")
+	var cmd = this.command("systemctl", "enable", serviceName).WithTimeout(5 * time.Second)
 	cmd.WithStderr()
 	err = cmd.Run()
 	if err != nil {
-		return errors.New("enable mysqld.service failed: " + cmd.Stderr())
+		return errors.New("enable " + serviceName + " failed: " + cmd.Stderr())
 	}
+	this.journal.Add("disable service '"+serviceName+"'", func() error {
+		return utils.NewTimeoutCmd(5*time.Second, "systemctl", "disable", serviceName).Run()
+	})
 
 	return nil
 }
 
-// install 'tar' command automatically
-func (this *FoolishInstaller) installTarCommand() error {
-	// dnf
-	dnfExe, err := exec.LookPath("dnf")
-	if err == nil && len(dnfExe) > 0 {
-		var cmd = utils.NewTimeoutCmd(10*time.Second, dnfExe, "-y", "install", "tar")
-		return cmd.Run()
-	}
-
-	// yum
-	yumExe, err := exec.LookPath("yum")
-	if err == nil && len(yumExe) > 0 {
-		var cmd = utils.NewTimeoutCmd(10*time.Second, yumExe, "-y", "install", "tar")
-		return cmd.Run()
+// check whether dir is inside of parent dir
+func (this *FoolishInstaller) isSubDir(parentDir string, dir string) bool {
+	parentDir, err := filepath.Abs(parentDir)
+	if err != nil {
+		return false
 	}
-
-	// apt-get
-	aptGetExe, err := exec.LookPath("apt-get")
-	if err == nil && len(aptGetExe) > 0 {
-		var cmd = utils.NewTimeoutCmd(10*time.Second, aptGetExe, "-y", "install", "tar")
-		err = cmd.Run()
-		if err != nil {
-			aptExe, aptErr := exec.LookPath("apt")
-			if aptErr == nil {
-				cmd = utils.NewTimeoutCmd(10*time.Second, aptExe, "-y", "install", "tar")
-				err = cmd.Run()
-			}
-		}
-		return err
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
 	}
-
-	return nil
+	return strings.HasPrefix(dir+"/", parentDir+"/")
 }
 
 func (this *FoolishInstaller) lookupGroupAdd() (string, error) {
@@ -695,3 +819,296 @@ func (this *FoolishInstaller) lookupUserAdd() (string, error) {
 	}
 	return "", errors.New("not found")
 }
+
+// detect architecture and glibc version of current host
+func (this *FoolishInstaller) detectPlatform() error {
+	if len(this.arch) == 0 {
+		arch, err := utils.HostArch()
+		if err != nil {
+			return err
+		}
+		this.arch = arch
+	}
+	if len(this.glibc) == 0 {
+		glibc, err := utils.HostGlibcVersion()
+		if err != nil {
+			return errors.New("detect glibc version failed: " + err.Error())
+		}
+		this.glibc = glibc
+	}
+	if utils.VersionCompare(this.glibc, "2.17") < 0 {
+		return errors.New("glibc " + this.glibc + " is too old, mysql requires glibc 2.17 at least")
+	}
+	return nil
+}
+
+// check the archive is built for current host before extracting it
+func (this *FoolishInstaller) checkArchivePlatform(archivePath string) error {
+	err := this.detectPlatform()
+	if err != nil {
+		return err
+	}
+
+	this.log("checking architecture of installer file ...")
+	arch, err := utils.DetectArchiveArch(archivePath)
+	if err != nil {
+		return errors.New("read installer file '" + archivePath + "' failed: " + err.Error())
+	}
+	if len(arch) == 0 {
+		return errors.New("could not find any executable in installer file '" + archivePath + "'")
+	}
+	if arch != this.arch {
+		return errors.New("architecture of installer file '" + archivePath + "' is '" + arch + "', but current host is '" + this.arch + "'")
+	}
+
+	var matches = archiveGlibcReg.FindStringSubmatch(filepath.Base(archivePath))
+	if len(matches) > 0 && utils.VersionCompare(matches[1], this.glibc) > 0 {
+		return errors.New("installer file '" + archivePath + "' requires glibc " + matches[1] + ", but current host has glibc " + this.glibc)
+	}
+	return nil
+}
+
+// extract archive file into dir, progress of each entry is sent to reporter as task 'extract'
+func (this *FoolishInstaller) extractArchive(archivePath string, targetDir string) error {
+	var lastTotal int64 = 0
+	err := utils.NewArchiveExtractor(archivePath).
+		WithContext(this.ctx).
+		OnProgress(func(name string, written int64, total int64) {
+			lastTotal = total
+			this.reporter.Progress("extract", written, total)
+		}).
+		ExtractTo(targetDir)
+	if err != nil {
+		return err
+	}
+
+	// padding after the last entry is not counted
+	if lastTotal > 0 {
+		this.reporter.Progress("extract", lastTotal, lastTotal)
+	}
+	return nil
+}
+
+// write file, or add it to plan in dry-run mode
+func (this *FoolishInstaller) writeFile(path string, data []byte, perm os.FileMode) error {
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionFile, Path: path, Mode: fmt.Sprintf("%04o", perm), Content: string(data)})
+		return nil
+	}
+	oldData, err := os.ReadFile(path)
+	if err == nil {
+		stat, statErr := os.Stat(path)
+		if statErr == nil {
+			this.journal.Add("restore file '"+path+"'", func() error {
+				return os.WriteFile(path, oldData, stat.Mode().Perm())
+			})
+		}
+	} else if os.IsNotExist(err) {
+		this.journal.Add("remove file '"+path+"'", func() error {
+			return os.Remove(path)
+		})
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// create dir and its parents, or add it to plan in dry-run mode
+func (this *FoolishInstaller) mkdir(dir string, perm os.FileMode) error {
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionDir, Path: dir, Mode: fmt.Sprintf("%04o", perm)})
+		return nil
+	}
+
+	// find the top dir to be created
+	var topDir = ""
+	for parentDir := filepath.Clean(dir); ; parentDir = filepath.Dir(parentDir) {
+		_, err := os.Stat(parentDir)
+		if err == nil || parentDir == filepath.Dir(parentDir) {
+			break
+		}
+		topDir = parentDir
+	}
+
+	err := os.MkdirAll(dir, perm)
+	if err != nil {
+		return err
+	}
+	if len(topDir) > 0 {
+		this.journal.Add("remove dir '"+topDir+"'", func() error {
+			return os.RemoveAll(topDir)
+		})
+	}
+	return nil
+}
+
+// rename file or dir, or add it to plan in dry-run mode
+func (this *FoolishInstaller) rename(oldPath string, newPath string) error {
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionRename, Path: oldPath, Target: newPath})
+		return nil
+	}
+	err := os.Rename(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	this.journal.Add("rename '"+newPath+"' back to '"+oldPath+"'", func() error {
+		return os.Rename(newPath, oldPath)
+	})
+	return nil
+}
+
+// remove file or empty dir, or add it to plan in dry-run mode
+func (this *FoolishInstaller) remove(path string) error {
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionRemove, Path: path})
+		return nil
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		this.journal.Add("recreate dir '"+path+"'", func() error {
+			return os.Mkdir(path, stat.Mode().Perm())
+		})
+	}
+	return nil
+}
+
+// remove file or dir recursively, or add it to plan in dry-run mode
+func (this *FoolishInstaller) removeAll(path string) error {
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionRemove, Path: path, Comment: "recursively"})
+		return nil
+	}
+	return os.RemoveAll(path)
+}
+
+// create symbolic link, or add it to plan in dry-run mode
+func (this *FoolishInstaller) symlink(target string, link string) error {
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionSymlink, Path: link, Target: target})
+		return nil
+	}
+	err := os.Symlink(target, link)
+	if err != nil {
+		return err
+	}
+	this.journal.Add("remove symbolic link '"+link+"'", func() error {
+		return os.Remove(link)
+	})
+	return nil
+}
+
+// guess mysql version from archive filename
+func archiveVersion(archivePath string) string {
+	var matches = archiveVersionReg.FindStringSubmatch(filepath.Base(archivePath))
+	if len(matches) > 0 {
+		return matches[1]
+	}
+	return ""
+}
+
+// guess top dir name in archive from archive filename
+func archiveDirName(archivePath string) string {
+	var name = filepath.Base(archivePath)
+	for _, ext := range []string{".tar.xz", ".tar.gz", ".tar"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// roll back all recorded steps of current installation
+func (this *FoolishInstaller) rollback() {
+	this.log("rolling back ...")
+
+	// changes should be rolled back completely even if context was canceled
+	var ctx = this.ctx
+	this.ctx = context.Background()
+	defer func() {
+		this.ctx = ctx
+	}()
+
+	rolledBack, failures := this.journal.Rollback()
+	for _, step := range rolledBack {
+		this.log("rolled back: " + step)
+	}
+	for _, failure := range failures {
+		this.warn("rollback: " + failure.Error())
+	}
+	this.rolledBack = rolledBack
+}
+
+// stop mysql server started by installer
+func (this *FoolishInstaller) stopServer(safeCmd *utils.Cmd, dataDir string) error {
+	// stop mysqld_safe first, or it will restart mysqld
+	var process = safeCmd.Process()
+	if process != nil {
+		_ = process.Kill()
+		_ = safeCmd.Wait()
+	}
+
+	return this.stopMysqld(dataDir)
+}
+
+// stop mysqld processes using the data dir
+func (this *FoolishInstaller) stopMysqld(dataDir string) error {
+	for _, pid := range this.findMysqldPids(dataDir) {
+		_ = syscall.Kill(pid, syscall.SIGTERM)
+	}
+	for i := 0; i < 30; i++ {
+		if len(this.findMysqldPids(dataDir)) == 0 {
+			return nil
+		}
+		time.Sleep(1 * time.Second)
+	}
+
+	var pids = this.findMysqldPids(dataDir)
+	for _, pid := range pids {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	}
+	if len(pids) > 0 {
+		return errors.New("mysqld did not stop in 30 seconds, killed")
+	}
+	return nil
+}
+
+// remove all entries in dir
+func (this *FoolishInstaller) cleanDir(dir string) error {
+	matches, err := filepath.Glob(dir + "/*")
+	if err != nil {
+		return err
+	}
+	// hidden files
+	hiddenMatches, _ := filepath.Glob(dir + "/.*")
+	for _, match := range append(matches, hiddenMatches...) {
+		err = os.RemoveAll(match)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete user or group with the first found command
+func (this *FoolishInstaller) deleteUserOrGroup(commands []string, name string) error {
+	for _, command := range commands {
+		commandPath, err := exec.LookPath(command)
+		if err != nil || len(commandPath) == 0 {
+			continue
+		}
+		var cmd = utils.NewCmd(commandPath, name)
+		cmd.WithStderr()
+		err = cmd.Run()
+		if err != nil {
+			return errors.New(cmd.Stderr())
+		}
+		return nil
+	}
+	return errors.New("could not find '" + strings.Join(commands, "' or '") + "' command")
+}
@@ -0,0 +1,458 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+	"foolishmysql/internal/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	browserUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/105.0.0.0 Safari/537.36"
+)
+
+func (this *FoolishInstaller) Download() (path string, err error) {
+	var client = this.httpClient(60 * time.Second)
+
+	err = this.detectPlatform()
+	if err != nil {
+		return "", err
+	}
+	this.log("platform: " + this.arch + ", glibc " + this.glibc)
+
+	// check version
+	version, err := this.resolveVersion(client)
+	if err != nil {
+		return "", err
+	}
+	this.log("found version: v" + version)
+
+	var pkg = this.packageInfo(version)
+
+	// local directory, use the file directly
+	if this.mirror != nil && this.mirror.IsLocal() {
+		return this.useLocalMirror(pkg)
+	}
+
+	// find in cache
+	var cached = false
+	if this.cache != nil {
+		cachedPath, ok := this.cache.Lookup(pkg)
+		if ok {
+			this.log("using cached package '" + cachedPath + "'")
+			path = cachedPath
+			cached = true
+		} else if this.dryRun {
+			path = this.cache.packageFile(pkg)
+		} else {
+			path, err = this.cache.PackagePath(pkg)
+			if err != nil {
+				return "", err
+			}
+		}
+	} else {
+		path = pkg.Name
+	}
+
+	var signatureURL = ""
+	if this.mirror != nil {
+		signatureURL = this.mirror.PackageURL(pkg) + ".asc"
+	} else {
+		signatureURL = mysqlSignatureURL + url.QueryEscape(pkg.Name)
+	}
+
+	if this.dryRun {
+		if !cached {
+			var packageURL string
+			if this.mirror != nil {
+				packageURL = this.mirror.PackageURL(pkg)
+			} else {
+				packageURL = VersionDownloadURLs(version, pkg.Name)[0]
+			}
+			this.plan.Add(&PlanAction{Type: PlanActionDownload, Name: packageURL, Path: path})
+		} else {
+			this.plan.AddCheck("cached package '" + path + "' matches its checksum")
+		}
+		if this.verifySignature && len(this.signatureFile) == 0 {
+			_, err = os.Stat(path + ".asc")
+			if err != nil {
+				this.plan.Add(&PlanAction{Type: PlanActionDownload, Name: signatureURL, Path: path + ".asc"})
+			}
+		}
+		return path, nil
+	}
+
+	if !cached {
+		if this.mirror != nil {
+			err = this.downloadFromMirror(client, pkg, path)
+		} else {
+			err = this.downloadFromCDN(client, pkg, path)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if this.cache != nil {
+			err = this.cache.Store(path)
+			if err != nil {
+				this.warn("failed to store package into cache: " + err.Error())
+			}
+		}
+	}
+
+	// download signature
+	if this.verifySignature && len(this.signatureFile) == 0 {
+		_, err = os.Stat(path + ".asc")
+		if err != nil {
+			this.log("downloading signature ...")
+			data, err := this.httpGet(client, signatureURL)
+			if err != nil {
+				return "", errors.New("download signature failed: " + err.Error())
+			}
+			var signature = findSignatureInPage(data)
+			if len(signature) == 0 {
+				return "", errors.New("download signature failed: could not find signature of '" + pkg.Name + "'")
+			}
+			err = os.WriteFile(path+".asc", signature, 0666)
+			if err != nil {
+				return "", errors.New("write signature file failed: " + err.Error())
+			}
+		}
+	}
+
+	return path, nil
+}
+
+// download package from mysql CDN, and verify it with published checksum
+func (this *FoolishInstaller) downloadFromCDN(client *http.Client, pkg *PackageInfo, path string) error {
+	this.log("start downloading ...")
+
+	var downloadURLs = VersionDownloadURLs(pkg.Version, pkg.Name)
+	for index, downloadURL := range downloadURLs {
+		err := this.downloadFile(downloadURL, path)
+		if err != nil {
+			if this.ctx.Err() != nil {
+				return this.ctx.Err()
+			}
+			if err == utils.ErrDownloadNotFound {
+				if index < len(downloadURLs)-1 {
+					continue
+				}
+				return errors.New("mysql version '" + pkg.Version + "' does not exist: could not find package '" + pkg.Name + "'")
+			}
+			return errors.New("download failed: " + err.Error())
+		}
+		break
+	}
+
+	// verify with published checksum
+	this.log("verifying checksum ...")
+	var publishedMD5 = this.fetchPublishedMD5(client, pkg.Version, pkg.Name)
+	if len(publishedMD5) == 0 {
+		err := this.checksumNotFound("could not find published checksum of '" + pkg.Name + "'")
+		if err != nil {
+			_ = os.Remove(path)
+			return err
+		}
+	} else {
+		err := utils.VerifyChecksums(path, publishedMD5, "")
+		if err != nil {
+			_ = os.Remove(path)
+			return errors.New("verify downloaded file failed: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// use package in local mirror
+// checksums are read from '.sha256' and '.md5' files beside the package
+func (this *FoolishInstaller) useLocalMirror(pkg *PackageInfo) (path string, err error) {
+	path = this.mirror.PackageURL(pkg)
+	this.log("using package '" + path + "' from local mirror ...")
+	stat, err := os.Stat(path)
+	if err != nil || stat.IsDir() {
+		return "", errors.New("mysql version '" + pkg.Version + "' does not exist: could not find '" + path + "' on local mirror")
+	}
+
+	var md5Sum, _ = os.ReadFile(path + ".md5")
+	var sha256Sum, _ = os.ReadFile(path + ".sha256")
+	err = this.verifyMirrorChecksums(path, md5Sum, sha256Sum)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// download package from remote mirror
+// checksums are read from '.sha256' and '.md5' files beside the package
+func (this *FoolishInstaller) downloadFromMirror(client *http.Client, pkg *PackageInfo, path string) error {
+	var packageURL = this.mirror.PackageURL(pkg)
+
+	this.log("start downloading ...")
+	err := this.downloadFile(packageURL, path)
+	if err != nil {
+		if this.ctx.Err() != nil {
+			return this.ctx.Err()
+		}
+		if err == utils.ErrDownloadNotFound {
+			return errors.New("mysql version '" + pkg.Version + "' does not exist: could not find '" + packageURL + "' on mirror")
+		}
+		return errors.New("download failed: " + err.Error())
+	}
+
+	var md5Sum, _ = this.httpGet(client, packageURL+".md5")
+	var sha256Sum, _ = this.httpGet(client, packageURL+".sha256")
+	err = this.verifyMirrorChecksums(path, md5Sum, sha256Sum)
+	if err != nil {
+		_ = os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// verify package with checksum files on mirror, content of checksum files is like 'CHECKSUM  FILENAME'
+func (this *FoolishInstaller) verifyMirrorChecksums(path string, md5Data []byte, sha256Data []byte) error {
+	var parseChecksum = func(data []byte) string {
+		var fields = strings.Fields(string(data))
+		if len(fields) > 0 {
+			return fields[0]
+		}
+		return ""
+	}
+	var md5Sum = parseChecksum(md5Data)
+	var sha256Sum = parseChecksum(sha256Data)
+	if len(md5Sum) == 0 && len(sha256Sum) == 0 {
+		return this.checksumNotFound("could not find checksum files of '" + filepath.Base(path) + "' on mirror")
+	}
+
+	this.log("verifying checksum ...")
+	err := utils.VerifyChecksums(path, md5Sum, sha256Sum)
+	if err != nil {
+		return errors.New("verify downloaded file failed: " + err.Error())
+	}
+	return nil
+}
+
+// archive without published checksum is rejected, unless it is verified with given checksum or signature later, or skipping is allowed explicitly
+func (this *FoolishInstaller) checksumNotFound(message string) error {
+	if len(this.md5Sum) > 0 || len(this.sha256Sum) > 0 || this.verifySignature {
+		this.log(message + ", archive will be verified with given checksum or signature")
+		return nil
+	}
+	if this.skipChecksum {
+		this.warn(message + ", skip verifying")
+		return nil
+	}
+	return errors.New(message + ", specify expected checksum with '--md5' or '--sha256', verify signature with '--verify-signature', or use '--skip-checksum' to install it anyway")
+}
+
+// download file with progress
+func (this *FoolishInstaller) downloadFile(downloadURL string, path string) error {
+	this.log("downloading from url '" + downloadURL + "' ...")
+	req, err := http.NewRequest(http.MethodGet, downloadURL, nil)
+	if err == nil {
+		proxyURL, _ := utils.ProxyFunc(this.proxy)(req)
+		if proxyURL != nil {
+			this.log("using proxy '" + proxyURL.Redacted() + "' ...")
+		}
+	}
+
+	err = utils.NewDownloader(downloadURL).
+		WithContext(this.ctx).
+		WithHeader("User-Agent", this.userAgent()).
+		WithProxy(this.proxy).
+		OnProgress(func(written int64, total int64) {
+			this.reporter.Progress("download", written, total)
+		}).
+		DownloadTo(path)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// fetch md5 checksum published by mysql
+func (this *FoolishInstaller) fetchPublishedMD5(client *http.Client, version string, packageName string) string {
+	for _, pageURL := range VersionChecksumPages(version) {
+		data, err := this.httpGet(client, pageURL)
+		if err != nil {
+			continue
+		}
+		var md5Sum = findMD5InPage(data, packageName)
+		if len(md5Sum) > 0 {
+			return md5Sum
+		}
+	}
+	return ""
+}
+
+// read page content
+func (this *FoolishInstaller) httpGet(client *http.Client, pageURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(this.ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", this.userAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("invalid response code: " + strconv.Itoa(resp.StatusCode))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// create http client with proxy
+func (this *FoolishInstaller) httpClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: utils.ProxyFunc(this.proxy),
+		},
+	}
+}
+
+// official sites may refuse requests without browser user agent, but mirrors don't have to
+func (this *FoolishInstaller) userAgent() string {
+	if this.mirror != nil {
+		return "foolish-mysql/" + Version
+	}
+	return browserUserAgent
+}
+
+// verify checksums and signature of archive file
+func (this *FoolishInstaller) verifyFile(archivePath string) error {
+	if len(this.md5Sum) > 0 || len(this.sha256Sum) > 0 {
+		this.log("verifying checksum ...")
+		err := utils.VerifyChecksums(archivePath, this.md5Sum, this.sha256Sum)
+		if err != nil {
+			return err
+		}
+	}
+
+	if this.verifySignature {
+		this.log("verifying signature ...")
+		var signatureFile = this.signatureFile
+		if len(signatureFile) == 0 {
+			signatureFile = archivePath + ".asc"
+		}
+		signature, err := os.ReadFile(signatureFile)
+		if err != nil {
+			return errors.New("read signature file failed: " + err.Error())
+		}
+
+		publicKey, err := this.readPublicKey()
+		if err != nil {
+			return err
+		}
+
+		err = utils.VerifySignature(archivePath, signature, publicKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolve version selector to a concrete release
+func (this *FoolishInstaller) resolveVersion(client *http.Client) (string, error) {
+	// concrete version
+	var series = ""
+	if len(this.version) > 0 {
+		selectorSeries, version, err := ParseVersionSelector(this.version)
+		if err != nil {
+			return "", err
+		}
+		if len(version) > 0 {
+			return version, nil
+		}
+		series = selectorSeries
+	}
+
+	// find versions on mirror
+	if this.mirror != nil {
+		this.log("checking mysql latest version on mirror ...")
+		return this.mirror.LatestVersion(series, this.packageName, func(indexURL string) ([]byte, error) {
+			return this.httpGet(client, indexURL)
+		})
+	}
+
+	var pageURL = mysqlDownloadsPage
+	if len(series) > 0 {
+		this.log("checking mysql latest version of series '" + series + "' ...")
+		pageURL += series + ".html"
+	} else {
+		this.log("checking mysql latest version ...")
+	}
+
+	req, err := http.NewRequestWithContext(this.ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("User-Agent", this.userAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.New("check latest version failed: " + err.Error())
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var version = ""
+	if resp.StatusCode == http.StatusOK {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", errors.New("read latest version failed: " + err.Error())
+		}
+		version = findVersionInPage(data, series)
+	}
+
+	if len(version) == 0 {
+		if len(series) > 0 {
+			return "", errors.New("could not find any release of mysql series '" + series + "'")
+		}
+		version = "8.2.0" // default version
+	}
+
+	return version, nil
+}
+
+// build package info for version, flavour and platform
+// packages built with glibc 2.28 are chosen on newer distributions, otherwise glibc 2.17
+func (this *FoolishInstaller) packageInfo(version string) *PackageInfo {
+	var pkg = &PackageInfo{
+		Version: version,
+		Major:   MajorVersion(version),
+		Arch:    this.arch,
+		Glibc:   "2.17",
+		Flavour: this.flavour,
+	}
+	if utils.VersionCompare(this.glibc, "2.28") >= 0 {
+		pkg.Glibc = "2.28"
+	}
+	pkg.Name = "mysql-" + version + "-linux-glibc" + pkg.Glibc + "-" + pkg.Arch
+	if this.flavour != FlavourFull {
+		pkg.Name += "-minimal"
+	}
+	pkg.Name += ".tar.xz"
+	return pkg
+}
+
+// build package filename for version and flavour
+func (this *FoolishInstaller) packageName(version string) string {
+	return this.packageInfo(version).Name
+}
@@ -0,0 +1,247 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+	"foolishmysql/internal/mysqlclient"
+	"foolishmysql/internal/utils"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultStartTimeout time to wait for a new server to accept connections
+	DefaultStartTimeout = 2 * time.Minute
+
+	errorLogTailLines = 20
+)
+
+var serverVersionReg = regexp.MustCompile(`^\d+\.\d+\.\d+`)
+
+// wait until mysqld spawned by mysqld_safe accepts connections, safeCmd is nil if server is started by systemd
+// server should report the version if it is not empty, the tail of error log is attached to the returned error
+func (this *FoolishInstaller) waitForServer(safeCmd *utils.Cmd, dataDir string, version string, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultStartTimeout
+	}
+	var safePid = 0
+	if safeCmd != nil && safeCmd.Process() != nil {
+		safePid = safeCmd.Process().Pid
+	}
+
+	var deadline = time.Now().Add(timeout)
+	var err error
+	for {
+		var serverVersion string
+		serverVersion, err = this.probeServerVersion(safePid, dataDir)
+		if err == nil {
+			if len(version) == 0 || serverVersion == version {
+				return nil
+			}
+			err = errors.New("server version is v" + serverVersion + ", expected v" + version)
+		}
+		if this.ctx.Err() != nil {
+			return this.ctx.Err()
+		}
+		if safePid > 0 && !utils.IsProcessAlive(safePid) {
+			err = errors.New("mysqld_safe exited unexpectedly")
+			break
+		}
+		if time.Now().After(deadline) {
+			err = errors.New("server was not ready in " + timeout.String() + ": " + err.Error())
+			break
+		}
+		sleepErr := this.sleep(500 * time.Millisecond)
+		if sleepErr != nil {
+			return sleepErr
+		}
+	}
+	return errors.New(err.Error() + this.errorLogTail(dataDir))
+}
+
+// check that the port is listened by mysqld spawned by mysqld_safe, and the server sends handshake on it
+// another process listening on the same port is not mistaken for the new server
+func (this *FoolishInstaller) probeServer(safePid int, dataDir string) error {
+	_, err := this.probeServerVersion(safePid, dataDir)
+	return err
+}
+
+// probe server like probeServer(), and read server version from handshake
+func (this *FoolishInstaller) probeServerVersion(safePid int, dataDir string) (string, error) {
+	var pids = []int{}
+	for _, pid := range this.findMysqldPids(dataDir) {
+		if safePid <= 0 || utils.IsDescendantProcess(pid, safePid) {
+			pids = append(pids, pid)
+		}
+	}
+	if len(pids) == 0 {
+		return "", errors.New("mysqld process is not running")
+	}
+
+	// another process may listen on the same port or socket
+	var network, address = this.probeAddress()
+	var listenerPid = 0
+	for _, pid := range pids {
+		if (network == "unix" && utils.ProcessListensOnUnixSocket(pid, address)) || (network == "tcp" && utils.ProcessListensOnPort(pid, this.port)) {
+			listenerPid = pid
+			break
+		}
+	}
+	if listenerPid == 0 {
+		if network == "unix" {
+			return "", errors.New("socket '" + address + "' is not listened by mysqld process, pid: '" + strconv.Itoa(pids[0]) + "'")
+		}
+		return "", errors.New("port '" + strconv.Itoa(this.port) + "' is not listened by mysqld process, pid: '" + strconv.Itoa(pids[0]) + "'")
+	}
+
+	serverVersion, err := mysqlclient.Probe(this.ctx, network, address, 5*time.Second)
+	if err != nil {
+		return "", errors.New("handshake with mysqld failed: " + err.Error())
+	}
+	return serverVersionReg.FindString(serverVersion), nil
+}
+
+// address to probe the server, unix socket is used if networking is disabled or server is not bound to loopback address in my.cnf
+func (this *FoolishInstaller) probeAddress() (network string, address string) {
+	var skipNetworking = false
+	var bindAddress = ""
+	for key, value := range this.myCnfOptions {
+		value = strings.ToLower(strings.Trim(strings.TrimSpace(value), "\"'"))
+		switch strings.ReplaceAll(strings.ToLower(key), "-", "_") {
+		case "skip_networking":
+			skipNetworking = value != "0" && value != "off" && value != "false"
+		case "bind_address":
+			bindAddress = value
+		}
+	}
+	if skipNetworking {
+		return "unix", this.socketFile()
+	}
+	if len(bindAddress) == 0 {
+		return "tcp", "127.0.0.1:" + strconv.Itoa(this.port)
+	}
+
+	// multiple addresses are supported since 8.0.13
+	for _, host := range strings.Split(bindAddress, ",") {
+		host = strings.TrimSpace(host)
+		switch host {
+		case "*", "localhost":
+			return "tcp", "127.0.0.1:" + strconv.Itoa(this.port)
+		}
+		var ip = net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		if ip.IsUnspecified() {
+			// '::' accepts both IPv4 and IPv6 connections
+			return "tcp", "127.0.0.1:" + strconv.Itoa(this.port)
+		}
+		if ip.IsLoopback() {
+			return "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(this.port))
+		}
+	}
+	return "unix", this.socketFile()
+}
+
+// find mysqld processes using the data dir
+func (this *FoolishInstaller) findMysqldPids(dataDir string) []int {
+	// working directory of process is the real path, data dir may be under a symbolic link
+	realDataDir, err := filepath.EvalSymlinks(dataDir)
+	if err == nil {
+		dataDir = realDataDir
+	}
+
+	// mysqld changes its working directory to data dir
+	var pids = []int{}
+	for _, pid := range utils.FindPidsWithName("mysqld") {
+		var cwd = utils.ProcessCwd(pid)
+		if len(cwd) > 0 && filepath.Clean(cwd) == filepath.Clean(dataDir) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// error log file of mysqld, default is '${dataDir}/${hostname}.err' when started by mysqld_safe
+func (this *FoolishInstaller) errorLogFile(dataDir string) string {
+	var errorLog = ""
+	for key, value := range this.myCnfOptions {
+		if strings.ReplaceAll(key, "-", "_") == "log_error" {
+			errorLog = strings.Trim(value, "\"'")
+		}
+	}
+	if len(errorLog) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return ""
+		}
+		errorLog = hostname
+	}
+	if !filepath.IsAbs(errorLog) {
+		errorLog = filepath.Join(dataDir, errorLog)
+	}
+	if len(filepath.Ext(errorLog)) == 0 {
+		errorLog += ".err"
+	}
+	return errorLog
+}
+
+// last lines of error log formatted to be appended to error messages, empty if error log is not readable
+func (this *FoolishInstaller) errorLogTail(dataDir string) string {
+	var errorLog = this.errorLogFile(dataDir)
+	if len(errorLog) == 0 {
+		return ""
+	}
+	var tail = readFileTail(errorLog, errorLogTailLines)
+	if len(tail) == 0 {
+		return ""
+	}
+	return "\nlast lines of error log '" + errorLog + "':\n" + tail
+}
+
+// read last lines of file
+func readFileTail(path string, maxLines int) string {
+	fp, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	// lines of error log are short, it is enough to read the last 16KB
+	const maxBytes = 16 << 10
+	stat, err := fp.Stat()
+	if err != nil {
+		return ""
+	}
+	var offset = stat.Size() - maxBytes
+	if offset < 0 {
+		offset = 0
+	}
+	_, err = fp.Seek(offset, io.SeekStart)
+	if err != nil {
+		return ""
+	}
+	data, err := io.ReadAll(fp)
+	if err != nil {
+		return ""
+	}
+
+	var lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if offset > 0 && len(lines) > 0 {
+		// first line may be incomplete
+		lines = lines[1:]
+	}
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,1124 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"foolishmysql/internal/mysqlclient"
+	"foolishmysql/internal/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type StepName = string
+
+const (
+	StepPreflight    StepName = "preflight"    // read-only checks of system, dirs and installer file
+	StepDependencies StepName = "dependencies" // install shared libraries required by mysql
+	StepUser         StepName = "user"         // create 'mysql' user and group
+	StepExtract      StepName = "extract"      // extract installer file into temporary dir
+	StepConfigure    StepName = "configure"    // create data dir and write my.cnf
+	StepInitialize   StepName = "initialize"   // initialize data dir
+	StepMove         StepName = "move"         // move files to target dir
+	StepStart        StepName = "start"        // start mysql server
+	StepSecure       StepName = "secure"       // change temporary root password
+	StepHarden       StepName = "harden"       // remove anonymous accounts, test database and remote root
+	StepAccounts     StepName = "accounts"     // create databases and users
+	StepLink         StepName = "link"         // link 'mysql' client command
+	StepService      StepName = "service"      // register systemd service
+)
+
+// AllSteps all installation steps in order
+var AllSteps = []StepName{
+	StepPreflight,
+	StepDependencies,
+	StepUser,
+	StepExtract,
+	StepConfigure,
+	StepInitialize,
+	StepMove,
+	StepStart,
+	StepSecure,
+	StepHarden,
+	StepAccounts,
+	StepLink,
+	StepService,
+}
+
+// ValidateSteps check step names
+func ValidateSteps(steps []StepName) error {
+	for _, step := range steps {
+		if !containsString(AllSteps, step) {
+			return errors.New("invalid step '" + step + "', available steps: " + strings.Join(AllSteps, ", "))
+		}
+	}
+	return nil
+}
+
+type StepStatus = string
+
+const (
+	StepStatusStarted   StepStatus = "started"
+	StepStatusCompleted StepStatus = "completed"
+	StepStatusFailed    StepStatus = "failed"
+	StepStatusSkipped   StepStatus = "skipped"
+)
+
+// StepEvent status change of an installation step
+type StepEvent struct {
+	Name     StepName   `json:"name"`
+	Status   StepStatus `json:"status"`
+	Duration float64    `json:"duration"` // seconds, for completed and failed steps
+	Error    string     `json:"error,omitempty"`
+}
+
+// DefaultStateDir default dir to store installation state files
+const DefaultStateDir = "/var/lib/foolish-mysql"
+
+// InstallState persisted state of installation, used to resume installation after crash or reboot
+type InstallState struct {
+	ArchivePath string `json:"archivePath"`
+	TargetDir   string `json:"targetDir"`
+
+	// options of installer
+	Instance      string `json:"instance"`
+	Port          int    `json:"port"`
+	Socket        string `json:"socket"`
+	DataDirOption string `json:"dataDirOption"`
+	TmpDirOption  string `json:"tmpDirOption"`
+
+	RootPasswordOption string            `json:"rootPasswordOption"`
+	PasswordLength     int               `json:"passwordLength"`
+	MyCnfOptions       map[string]string `json:"myCnfOptions"`
+	ServiceRestart     string            `json:"serviceRestart"`
+	DependencyPolicy   DependencyPolicy  `json:"dependencyPolicy"`
+	Databases          []*DatabaseConfig `json:"databases"`
+	Users              []*UserConfig     `json:"users"`
+	NoHardening        bool              `json:"noHardening"`
+	RootAuthSocket     bool              `json:"rootAuthSocket"`
+
+	Version           string `json:"version"`         // version of mysql in installer file
+	ExtractDir        string `json:"extractDir"`      // temporary dir to extract files
+	BaseDir           string `json:"baseDir"`         // current base dir, it is in extract dir before moving
+	ExternalDataDir   string `json:"externalDataDir"` // data dir outside of base dir
+	TemporaryPassword string `json:"temporaryPassword"`
+	RootPassword      string `json:"rootPassword"`
+
+	HardeningChanges []string `json:"hardeningChanges"` // changes made by hardening
+
+	CompletedSteps []StepName `json:"completedSteps"`
+	UpdatedAt      int64      `json:"updatedAt"`
+}
+
+// DataDir get current data dir
+func (this *InstallState) DataDir() string {
+	if len(this.ExternalDataDir) > 0 {
+		return this.ExternalDataDir
+	}
+	return this.BaseDir + "/data"
+}
+
+// IsCompleted check whether the step was completed
+func (this *InstallState) IsCompleted(step StepName) bool {
+	return containsString(this.CompletedSteps, step)
+}
+
+func (this *InstallState) complete(step StepName) {
+	if !this.IsCompleted(step) {
+		this.CompletedSteps = append(this.CompletedSteps, step)
+	}
+}
+
+// step function of installation
+type installStepFunc = func(state *InstallState) error
+
+// run steps in order, steps completed in previous runs are skipped if resuming
+func (this *FoolishInstaller) runSteps(state *InstallState) error {
+	var funcs = map[StepName]installStepFunc{
+		StepPreflight:    this.stepPreflight,
+		StepDependencies: this.stepDependencies,
+		StepUser:         this.stepUser,
+		StepExtract:      this.stepExtract,
+		StepConfigure:    this.stepConfigure,
+		StepInitialize:   this.stepInitialize,
+		StepMove:         this.stepMove,
+		StepStart:        this.stepStart,
+		StepSecure:       this.stepSecure,
+		StepHarden:       this.stepHarden,
+		StepAccounts:     this.stepAccounts,
+		StepLink:         this.stepLink,
+		StepService:      this.stepService,
+	}
+
+	// state before this run, restored after rolling back
+	var initialState = *state
+	initialState.CompletedSteps = append([]StepName{}, state.CompletedSteps...)
+
+	for _, step := range AllSteps {
+		if len(this.onlySteps) > 0 && !containsString(this.onlySteps, step) {
+			continue
+		}
+		if containsString(this.skipSteps, step) {
+			this.reporter.StepFinished(&StepEvent{Name: step, Status: StepStatusSkipped})
+			continue
+		}
+		if this.resume && state.IsCompleted(step) && step != StepPreflight {
+			this.reporter.StepFinished(&StepEvent{Name: step, Status: StepStatusSkipped})
+			continue
+		}
+
+		this.reporter.StepStarted(step)
+		var startedAt = time.Now()
+		var err = this.ctx.Err()
+		if err == nil {
+			err = funcs[step](state)
+
+			// errors of killed commands are replaced by the reason
+			if err != nil && this.ctx.Err() != nil {
+				err = this.ctx.Err()
+			}
+		}
+		if err != nil {
+			this.reporter.StepFinished(&StepEvent{Name: step, Status: StepStatusFailed, Duration: time.Since(startedAt).Seconds(), Error: err.Error()})
+
+			// steps of this run will be rolled back
+			if !this.dryRun && this.journal.Len() > 0 {
+				this.rollback()
+				*state = initialState
+			}
+			_ = this.saveState(state)
+			return err
+		}
+		this.reporter.StepFinished(&StepEvent{Name: step, Status: StepStatusCompleted, Duration: time.Since(startedAt).Seconds()})
+		state.complete(step)
+		err = this.saveState(state)
+		if err != nil {
+			this.warn("save installation state failed: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// StateFile get path of installation state file
+func (this *FoolishInstaller) StateFile() string {
+	if len(this.instance) > 0 {
+		return this.stateDir + "/install-" + this.instance + ".json"
+	}
+	return this.stateDir + "/install.json"
+}
+
+// save state to state file, ignored in dry-run mode
+func (this *FoolishInstaller) saveState(state *InstallState) error {
+	if this.dryRun {
+		return nil
+	}
+	err := os.MkdirAll(this.stateDir, 0700)
+	if err != nil {
+		return err
+	}
+	state.UpdatedAt = time.Now().Unix()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// passwords are in state, so it should be readable only for root
+	return os.WriteFile(this.StateFile(), data, 0600)
+}
+
+// load state from state file
+func (this *FoolishInstaller) loadState() (*InstallState, error) {
+	data, err := os.ReadFile(this.StateFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("could not find installation state file '" + this.StateFile() + "' to resume")
+		}
+		return nil, err
+	}
+	var state = &InstallState{}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, errors.New("invalid installation state file '" + this.StateFile() + "': " + err.Error())
+	}
+	return state, nil
+}
+
+// check system, dirs and installer file
+func (this *FoolishInstaller) stepPreflight(state *InstallState) error {
+	if len(this.instance) > 0 {
+		err := ValidateInstanceName(this.instance)
+		if err != nil {
+			return err
+		}
+		if this.mysqlxPort() == 0 {
+			this.warn("X Plugin will be disabled, because its port can not be derived from port '" + strconv.Itoa(this.port) + "', set 'mysqlx_port' in my.cnf options to enable it")
+		}
+	}
+
+	// check whether another mysql server is using the same port or data dir
+	// server of the installation may be started already when resuming
+	if !state.IsCompleted(StepStart) {
+		this.log("checking mysqld ...")
+		var finalDataDir = this.dataDir
+		if len(finalDataDir) == 0 {
+			finalDataDir = state.TargetDir + "/data"
+		}
+		err := this.checkConflicts(finalDataDir)
+		if err != nil {
+			return err
+		}
+		this.plan.AddCheck("port " + strconv.Itoa(this.port) + " and data dir '" + finalDataDir + "' are not used by other mysql servers")
+	}
+
+	// check target dir
+	if !state.IsCompleted(StepMove) {
+		this.log("checking target dir '" + state.TargetDir + "' ...")
+		matches, _ := filepath.Glob(state.TargetDir + "/*")
+		if len(matches) > 0 {
+			return errors.New("target dir '" + state.TargetDir + "' already exists and not empty")
+		}
+		this.plan.AddCheck("target dir '" + state.TargetDir + "' does not exist or is empty")
+	}
+
+	// check commands
+	this.log("checking system commands ...")
+	var cmdList = []string{"chown", "sh"}
+	for _, cmd := range cmdList {
+		cmdPath, err := exec.LookPath(cmd)
+		if err != nil || len(cmdPath) == 0 {
+			return errors.New("could not find '" + cmd + "' command in this system")
+		}
+	}
+
+	groupAddExe, err := this.lookupGroupAdd()
+	if err != nil {
+		return errors.New("could not find 'groupadd' command in this system")
+	}
+
+	userAddExe, err := this.lookupUserAdd()
+	if err != nil {
+		return errors.New("could not find 'useradd' command in this system")
+	}
+	this.plan.AddCheck("found commands: chown, sh, " + groupAddExe + ", " + userAddExe)
+
+	// check data dir
+	if len(this.dataDir) > 0 && !state.IsCompleted(StepInitialize) {
+		this.log("checking data dir '" + this.dataDir + "' ...")
+		if this.isSubDir(state.TargetDir, this.dataDir) && filepath.Clean(this.dataDir) != filepath.Clean(state.TargetDir+"/data") {
+			return errors.New("data dir '" + this.dataDir + "' should not be inside of base dir '" + state.TargetDir + "' except '" + state.TargetDir + "/data'")
+		}
+		matches, _ := filepath.Glob(this.dataDir + "/*")
+		if len(matches) > 0 {
+			return errors.New("data dir '" + this.dataDir + "' already exists and not empty")
+		}
+		this.plan.AddCheck("data dir '" + this.dataDir + "' does not exist or is empty")
+	}
+
+	// check installer file
+	if state.IsCompleted(StepExtract) {
+		return nil
+	}
+	this.log("checking installer file ...")
+	if this.dryRun && this.plan.hasDownload(state.ArchivePath) {
+		this.plan.AddCheck("installer file '" + state.ArchivePath + "' will be verified after downloading")
+		return nil
+	}
+
+	var archivePath = state.ArchivePath
+	stat, err := os.Stat(archivePath)
+	if err != nil {
+		return errors.New("could not open the installer file: " + err.Error())
+	}
+	if stat.IsDir() {
+		return errors.New("'" + archivePath + "' not a valid file")
+	}
+
+	format, err := utils.DetectArchiveFormat(archivePath)
+	if err != nil {
+		return errors.New("invalid installer file '" + archivePath + "': " + err.Error())
+	}
+	this.log("installer file format: " + format)
+
+	err = this.verifyFile(archivePath)
+	if err != nil {
+		return errors.New("verify installer file '" + archivePath + "' failed: " + err.Error())
+	}
+
+	err = this.checkArchivePlatform(archivePath)
+	if err != nil {
+		return err
+	}
+	this.plan.AddCheck("installer file '" + archivePath + "' is a valid " + format + " archive for " + this.arch)
+
+	return nil
+}
+
+// install shared libraries required by mysql
+func (this *FoolishInstaller) stepDependencies(state *InstallState) error {
+	switch this.dependencyPolicy {
+	case DependenciesSkip:
+		this.log("skip checking shared libraries")
+		return nil
+	case DependenciesCheck:
+		return this.checkSharedLibraries()
+	}
+
+	// ubuntu apt
+	aptGetExe, err := exec.LookPath("apt-get")
+	if err == nil && len(aptGetExe) > 0 {
+		for _, lib := range []string{"libaio1", "libncurses5", "libnuma1"} {
+			if this.dryRun {
+				var action = &PlanAction{Type: PlanActionPackage, Name: lib, Command: []string{aptGetExe, "-y", "install", lib}}
+				if lib == "libnuma1" {
+					action.Comment = "optional, failure will be ignored"
+				}
+				this.plan.Add(action)
+				continue
+			}
+
+			this.log("checking " + lib + " ...")
+			var cmd = this.command(aptGetExe, "-y", "install", lib)
+			cmd.WithStderr()
+			err = cmd.Run()
+			if err != nil {
+				// try apt
+				aptExe, aptErr := exec.LookPath("apt")
+				if aptErr == nil && len(aptExe) > 0 {
+					cmd = this.command(aptExe, "-y", "install", lib)
+					cmd.WithStderr()
+					err = cmd.Run()
+				}
+
+				if err != nil {
+					if this.ctx.Err() != nil {
+						return this.ctx.Err()
+					}
+					if lib == "libnuma1" {
+						err = nil
+					} else {
+						return errors.New("install " + lib + " failed: " + cmd.Stderr())
+					}
+				}
+			}
+			err = this.sleep(1 * time.Second)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// yum
+	yumExe, err := exec.LookPath("yum")
+	if err == nil && len(yumExe) > 0 {
+		for _, lib := range []string{"libaio", "ncurses-libs", "ncurses-compat-libs", "numactl-libs"} {
+			if this.dryRun {
+				this.plan.Add(&PlanAction{Type: PlanActionPackage, Name: lib, Command: []string{"yum", "-y", "install", lib}, Comment: "failure will be ignored"})
+				continue
+			}
+			var cmd = this.command("yum", "-y", "install", lib)
+			_ = cmd.Run()
+			err = this.sleep(1 * time.Second)
+			if err != nil {
+				return err
+			}
+		}
+
+		// create symbolic links for libraries of ncurses 6 on yum based systems
+		for _, lib := range []string{"libncurses", "libtinfo"} {
+			var libFile = "/usr/lib64/" + lib + ".so.5"
+			_, err = os.Stat(libFile)
+			if err != nil && os.IsNotExist(err) {
+				var latestLibFile = utils.FindLatestVersionFile("/usr/lib64", lib+".so.")
+				if len(latestLibFile) > 0 {
+					this.log("link '" + latestLibFile + "' to '" + libFile + "'")
+					_ = this.symlink(latestLibFile, libFile)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// check shared libraries required by mysql without installing them
+func (this *FoolishInstaller) checkSharedLibraries() error {
+	this.log("checking shared libraries ...")
+	ldconfigExe, err := exec.LookPath("ldconfig")
+	if err != nil {
+		ldconfigExe = "/sbin/ldconfig"
+	}
+	var cmd = this.command(ldconfigExe, "-p").WithTimeout(10 * time.Second)
+	cmd.WithStdout()
+	cmd.WithStderr()
+	err = cmd.Run()
+	if err != nil {
+		return errors.New("list shared libraries failed: " + err.Error() + ": " + cmd.Stderr())
+	}
+	var libs = cmd.Stdout()
+	for _, lib := range []string{"libaio.so.1"} {
+		if !strings.Contains(libs, lib+" ") {
+			return errors.New("shared library '" + lib + "' is missing, please install it, or use dependency policy '" + DependenciesInstall + "'")
+		}
+		this.plan.AddCheck("shared library '" + lib + "' exists")
+	}
+	if !strings.Contains(libs, "libnuma.so.1 ") {
+		this.warn("optional shared library 'libnuma.so.1' is missing")
+	}
+	return nil
+}
+
+// create 'mysql' user and group
+func (this *FoolishInstaller) stepUser(state *InstallState) error {
+	groupAddExe, err := this.lookupGroupAdd()
+	if err != nil {
+		return errors.New("could not find 'groupadd' command in this system")
+	}
+
+	userAddExe, err := this.lookupUserAdd()
+	if err != nil {
+		return errors.New("could not find 'useradd' command in this system")
+	}
+
+	// create 'mysql' user group
+	this.log("checking 'mysql' user group ...")
+	{
+		data, err := os.ReadFile("/etc/group")
+		if err != nil {
+			return errors.New("check user group failed: " + err.Error())
+		}
+		if !bytes.Contains(data, []byte("\nmysql:")) {
+			if this.dryRun {
+				this.plan.Add(&PlanAction{Type: PlanActionGroup, Name: "mysql", Command: []string{groupAddExe, "mysql"}})
+			} else {
+				var cmd = this.command(groupAddExe, "mysql")
+				cmd.WithStderr()
+				err = cmd.Run()
+				if err != nil {
+					return errors.New("add 'mysql' user group failed: " + cmd.Stderr())
+				}
+				this.journal.Add("delete user group 'mysql'", func() error {
+					return this.deleteUserOrGroup([]string{"groupdel", "delgroup"}, "mysql")
+				})
+			}
+		} else {
+			this.plan.AddCheck("user group 'mysql' exists")
+		}
+	}
+
+	// create 'mysql' user
+	this.log("checking 'mysql' user ...")
+	{
+		data, err := os.ReadFile("/etc/passwd")
+		if err != nil {
+			return errors.New("check user failed: " + err.Error())
+		}
+		if !bytes.Contains(data, []byte("\nmysql:")) {
+			var args []string
+			if strings.HasSuffix(userAddExe, "useradd") {
+				args = []string{"mysql", "-g", "mysql"}
+			} else { // adduser
+				args = []string{"-S", "-G", "mysql", "mysql"}
+			}
+			if this.dryRun {
+				this.plan.Add(&PlanAction{Type: PlanActionUser, Name: "mysql", Command: append([]string{userAddExe}, args...)})
+			} else {
+				var cmd = this.command(userAddExe, args...)
+				cmd.WithStderr()
+				err = cmd.Run()
+				if err != nil {
+					return errors.New("add 'mysql' user failed: " + cmd.Stderr())
+				}
+				this.journal.Add("delete user 'mysql'", func() error {
+					return this.deleteUserOrGroup([]string{"userdel", "deluser"}, "mysql")
+				})
+			}
+		} else {
+			this.plan.AddCheck("user 'mysql' exists")
+		}
+	}
+
+	return nil
+}
+
+// extract installer file into temporary dir
+// extract files beside target dir, so they can be moved with renaming even if system temporary dir is on another device
+func (this *FoolishInstaller) stepExtract(state *InstallState) error {
+	// mkdir
+	var parentDir = filepath.Dir(state.TargetDir)
+	stat, err := os.Stat(parentDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = this.mkdir(parentDir, 0755)
+			if err != nil {
+				return errors.New("try to create dir '" + parentDir + "' failed: " + err.Error())
+			}
+		} else {
+			return errors.New("check dir '" + parentDir + "' failed: " + err.Error())
+		}
+	} else if !stat.IsDir() {
+		return errors.New("'" + parentDir + "' should be a directory")
+	}
+
+	this.log("extracting installer file ...")
+	state.ExtractDir = parentDir + "/.foolish-mysql-tmp"
+	_, err = os.Stat(state.ExtractDir)
+	if err == nil {
+		err = this.removeAll(state.ExtractDir)
+		if err != nil {
+			return errors.New("clean temporary directory '" + state.ExtractDir + "' failed: " + err.Error())
+		}
+	}
+	err = this.mkdir(state.ExtractDir, 0777)
+	if err != nil {
+		return errors.New("create temporary directory '" + state.ExtractDir + "' failed: " + err.Error())
+	}
+
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionExtract, Name: state.ArchivePath, Path: state.ExtractDir})
+		state.BaseDir = state.ExtractDir + "/" + archiveDirName(state.ArchivePath)
+		state.Version = archiveVersion(state.ArchivePath)
+		return nil
+	}
+
+	err = this.extractArchive(state.ArchivePath, state.ExtractDir)
+	if err != nil {
+		return errors.New("extract installer file '" + state.ArchivePath + "' failed: " + err.Error())
+	}
+
+	matches, err := filepath.Glob(state.ExtractDir + "/mysql-*")
+	if err != nil || len(matches) == 0 {
+		return errors.New("could not find mysql installer directory from '" + state.ExtractDir + "'")
+	}
+	state.BaseDir = matches[0]
+
+	version, err := this.mysqldVersion(state.BaseDir)
+	if err != nil {
+		this.warn("read version of mysql failed: " + err.Error())
+		version = archiveVersion(state.ArchivePath)
+	}
+	state.Version = version
+	return nil
+}
+
+// create data dir and write my.cnf
+func (this *FoolishInstaller) stepConfigure(state *InstallState) error {
+	var mysqlDirs = []string{state.DataDir()}
+	if len(this.tmpDir) > 0 {
+		mysqlDirs = append(mysqlDirs, this.tmpDir)
+	}
+	for _, dir := range mysqlDirs {
+		_, err := os.Stat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// parent dirs should be accessible for 'mysql' user
+				err = this.mkdir(dir, 0755)
+				if err != nil {
+					return errors.New("create dir '" + dir + "' failed: " + err.Error())
+				}
+			} else {
+				return errors.New("check dir '" + dir + "' failed: " + err.Error())
+			}
+		}
+
+		// chown
+		if this.dryRun {
+			this.plan.Add(&PlanAction{Type: PlanActionCommand, Command: []string{"chown", "mysql:mysql", dir}})
+			continue
+		}
+		var cmd = this.command("chown", "mysql:mysql", dir)
+		cmd.WithStderr()
+		err = cmd.Run()
+		if err != nil {
+			return errors.New("chown dir '" + dir + "' failed: " + cmd.Stderr())
+		}
+	}
+
+	// create my.cnf
+	var myCnfFile = this.ConfigFile()
+	_, err := os.Stat(myCnfFile)
+	if err == nil {
+		// backup it
+		err = this.rename(myCnfFile, myCnfFile+"."+utils.Format("YmdHis"))
+		if err != nil {
+			return errors.New("backup '" + myCnfFile + "' failed: " + err.Error())
+		}
+	}
+
+	// mysql server options https://dev.mysql.com/doc/refman/8.0/en/server-system-variables.html
+	err = this.writeFile(myCnfFile, []byte(this.createMyCnf(state.BaseDir, state.DataDir())), 0666)
+	if err != nil {
+		return errors.New("write '" + myCnfFile + "' failed: " + err.Error())
+	}
+	return nil
+}
+
+// initialize data dir and keep the temporary password
+func (this *FoolishInstaller) stepInitialize(state *InstallState) error {
+	this.log("initializing mysql ...")
+	{
+		// data dir is empty before initializing
+		var initializedDataDir = state.DataDir()
+		this.journal.Add("clean data dir '"+initializedDataDir+"'", func() error {
+			return this.cleanDir(initializedDataDir)
+		})
+	}
+
+	var args = []string{"--defaults-file=" + this.ConfigFile(), "--initialize", "--user=mysql"}
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionCommand, Command: append([]string{state.BaseDir + "/bin/mysqld"}, args...)})
+		state.TemporaryPassword = "<temporary password>"
+	} else {
+		var cmd = this.command(state.BaseDir+"/bin/mysqld", args...)
+		cmd.WithStderr()
+		cmd.WithStdout()
+		err := cmd.Run()
+		if err != nil {
+			return errors.New("initialize failed: " + cmd.Stderr())
+		}
+
+		// read from stdout
+		var match = regexp.MustCompile(`temporary password is.+:\s*(.+)`).FindStringSubmatch(cmd.Stdout())
+		if len(match) == 0 {
+			// read from stderr
+			match = regexp.MustCompile(`temporary password is.+:\s*(.+)`).FindStringSubmatch(cmd.Stderr())
+
+			if len(match) == 0 {
+				return errors.New("initialize successfully, but could not find generated password, please report to developer")
+			}
+		}
+		state.TemporaryPassword = strings.TrimSpace(match[1])
+	}
+
+	// write password to file
+	err := this.writeFile(this.credentialFile(state.BaseDir), []byte(state.TemporaryPassword+"\n"), 0600)
+	if err != nil {
+		return errors.New("write password failed: " + err.Error())
+	}
+	return nil
+}
+
+// move files to target dir, and change dirs in my.cnf
+func (this *FoolishInstaller) stepMove(state *InstallState) error {
+	if state.BaseDir != state.TargetDir {
+		this.log("moving files to target dir ...")
+
+		// remove empty target dir
+		_, err := os.Stat(state.TargetDir)
+		if err == nil {
+			err = this.remove(state.TargetDir)
+			if err != nil {
+				return errors.New("clean target dir '" + state.TargetDir + "' failed: " + err.Error())
+			}
+		}
+
+		err = this.rename(state.BaseDir, state.TargetDir)
+		if err != nil {
+			return errors.New("move '" + state.BaseDir + "' to '" + state.TargetDir + "' failed: " + err.Error())
+		}
+		state.BaseDir = state.TargetDir
+	}
+
+	// change my.cnf
+	var myCnfFile = this.ConfigFile()
+	err := this.writeFile(myCnfFile, []byte(this.createMyCnf(state.BaseDir, state.DataDir())), 0666)
+	if err != nil {
+		return errors.New("create new '" + myCnfFile + "' failed: " + err.Error())
+	}
+	return nil
+}
+
+// start mysql server
+func (this *FoolishInstaller) stepStart(state *InstallState) error {
+	this.log("starting mysql ...")
+	var args = []string{"--defaults-file=" + this.ConfigFile(), "--user=mysql"}
+	if this.dryRun {
+		this.plan.Add(&PlanAction{Type: PlanActionCommand, Command: append([]string{state.BaseDir + "/bin/mysqld_safe"}, args...), Comment: "start mysql server in background"})
+		return nil
+	}
+
+	var cmd = utils.NewCmd(state.BaseDir+"/bin/mysqld_safe", args...)
+	cmd.WithStderr()
+	err := cmd.Start()
+	if err != nil {
+		return errors.New("start failed '" + cmd.String() + "': " + cmd.Stderr())
+	}
+	var startedDataDir = state.DataDir()
+	this.journal.Add("stop mysql server", func() error {
+		return this.stopServer(cmd, startedDataDir)
+	})
+
+	// waiting for startup
+	err = this.waitForServer(cmd, startedDataDir, "", this.startTimeout)
+	if err != nil {
+		return errors.New("start failed: " + err.Error())
+	}
+	return nil
+}
+
+// change temporary root password
+func (this *FoolishInstaller) stepSecure(state *InstallState) error {
+	// server may be stopped after reboot when resuming
+	if !this.dryRun && state.IsCompleted(StepStart) && this.probeServer(0, state.DataDir()) != nil {
+		err := this.stepStart(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	var newPassword = this.rootPassword
+	if len(newPassword) == 0 {
+		generatedPassword, generateErr := this.generatePassword()
+		if generateErr != nil {
+			return errors.New("generate new password failed: " + generateErr.Error())
+		}
+		newPassword = generatedPassword
+		if this.dryRun {
+			newPassword = "<generated password>"
+		}
+	} else if this.dryRun {
+		newPassword = "<root password>"
+	}
+
+	this.log("changing mysql password ...")
+	var passwordSQL = "ALTER USER 'root'@'localhost' IDENTIFIED BY " + mysqlclient.QuoteString(newPassword)
+	err := this.execSQL(state.TemporaryPassword, []string{passwordSQL}, true)
+	if err != nil {
+		return errors.New("change password failed: " + err.Error())
+	}
+	state.RootPassword = newPassword
+	this.password = newPassword
+
+	err = this.writeFile(this.credentialFile(state.BaseDir), []byte(this.password), 0600)
+	if err != nil {
+		return errors.New("write generated file failed: " + err.Error())
+	}
+	return nil
+}
+
+// remove anonymous accounts, test database and remote root like mysql_secure_installation, and switch root to auth_socket optionally
+func (this *FoolishInstaller) stepHarden(state *InstallState) error {
+	if !this.hardening {
+		this.log("hardening is disabled")
+		return nil
+	}
+
+	// server may be stopped after reboot when resuming
+	if !this.dryRun && this.probeServer(0, state.DataDir()) != nil {
+		err := this.stepStart(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	this.log("hardening mysql server ...")
+	if this.dryRun {
+		var sqlList = []string{
+			"DROP USER ''@'${host}' -- for every anonymous account",
+			"DROP DATABASE `test`",
+			"DELETE FROM `mysql`.`db` WHERE `Db` = 'test' OR `Db` = 'test\\\\_%'",
+			"DROP USER 'root'@'${host}' -- for every root account not on localhost",
+		}
+		if this.rootAuthSocket {
+			sqlList = append(sqlList, "INSTALL PLUGIN auth_socket SONAME 'auth_socket.so' -- if it is not installed", "ALTER USER 'root'@'localhost' IDENTIFIED WITH auth_socket")
+		}
+		sqlList = append(sqlList, "FLUSH PRIVILEGES")
+		this.plan.Add(&PlanAction{Type: PlanActionSQL, Name: "root@localhost", Path: this.socketFile(), Content: strings.Join(sqlList, ";\n") + ";\n", Comment: "statements are executed only for existing accounts, database and privileges"})
+		if this.rootAuthSocket {
+			_ = this.remove(this.credentialFile(state.BaseDir))
+		}
+		return nil
+	}
+
+	conn, err := this.connect(this.password, false)
+	if err != nil {
+		return errors.New("connect to server failed: " + err.Error())
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var changes = []string{}
+	var change = func(sql string, description string) error {
+		err := conn.Exec(this.ctx, sql)
+		if err != nil {
+			return errors.New(description + " failed: " + err.Error())
+		}
+		this.log(description)
+		changes = append(changes, description)
+		return nil
+	}
+	var query = func(sql string) ([]string, error) {
+		result, err := conn.Query(this.ctx, sql)
+		if err != nil {
+			return nil, err
+		}
+		var values = []string{}
+		for _, row := range result.Rows {
+			if len(row) > 0 && row[0] != nil {
+				values = append(values, *row[0])
+			}
+		}
+		return values, nil
+	}
+
+	// anonymous accounts
+	hosts, err := query("SELECT `Host` FROM `mysql`.`user` WHERE `User` = ''")
+	if err != nil {
+		return errors.New("query anonymous accounts failed: " + err.Error())
+	}
+	for _, host := range hosts {
+		err = change("DROP USER ''@"+mysqlclient.QuoteString(host), "remove anonymous account ''@'"+host+"'")
+		if err != nil {
+			return err
+		}
+	}
+
+	// test database and privileges on it, including databases starting with 'test_'
+	databases, err := query("SELECT `SCHEMA_NAME` FROM `information_schema`.`SCHEMATA` WHERE `SCHEMA_NAME` = 'test'")
+	if err != nil {
+		return errors.New("query test database failed: " + err.Error())
+	}
+	if len(databases) > 0 {
+		err = change("DROP DATABASE `test`", "drop database 'test'")
+		if err != nil {
+			return err
+		}
+	}
+	var testDBCondition = "`Db` = 'test' OR `Db` = " + mysqlclient.QuoteString(`test\_%`)
+	grantHosts, err := query("SELECT DISTINCT `Host` FROM `mysql`.`db` WHERE " + testDBCondition)
+	if err != nil {
+		return errors.New("query privileges on test database failed: " + err.Error())
+	}
+	var flushPrivileges = false
+	if len(grantHosts) > 0 {
+		err = change("DELETE FROM `mysql`.`db` WHERE "+testDBCondition, "remove privileges on database 'test' and 'test_%'")
+		if err != nil {
+			return err
+		}
+		flushPrivileges = true
+	}
+
+	// root can only login from local host
+	hosts, err = query("SELECT `Host` FROM `mysql`.`user` WHERE `User` = 'root' AND `Host` NOT IN ('localhost', '127.0.0.1', '::1')")
+	if err != nil {
+		return errors.New("query root accounts failed: " + err.Error())
+	}
+	for _, host := range hosts {
+		err = change("DROP USER 'root'@"+mysqlclient.QuoteString(host), "remove remote root account 'root'@'"+host+"'")
+		if err != nil {
+			return err
+		}
+	}
+
+	if flushPrivileges {
+		err = conn.Exec(this.ctx, "FLUSH PRIVILEGES")
+		if err != nil {
+			return errors.New("flush privileges failed: " + err.Error())
+		}
+	}
+
+	// root password will not work any more
+	if this.rootAuthSocket {
+		plugins, err := query("SELECT `PLUGIN_NAME` FROM `information_schema`.`PLUGINS` WHERE `PLUGIN_NAME` = 'auth_socket' AND `PLUGIN_STATUS` = 'ACTIVE'")
+		if err != nil {
+			return errors.New("query auth_socket plugin failed: " + err.Error())
+		}
+		if len(plugins) == 0 {
+			err = change("INSTALL PLUGIN auth_socket SONAME 'auth_socket.so'", "install plugin 'auth_socket'")
+			if err != nil {
+				return err
+			}
+		}
+		err = change("ALTER USER 'root'@'localhost' IDENTIFIED WITH auth_socket", "switch 'root'@'localhost' to auth_socket, login as system user 'root' with unix socket")
+		if err != nil {
+			return err
+		}
+		_ = this.remove(this.credentialFile(state.BaseDir))
+		this.password = ""
+		state.RootPassword = ""
+	}
+
+	if len(changes) == 0 {
+		this.log("no anonymous accounts, test database or remote root accounts found")
+	}
+	this.hardeningChanges = changes
+	state.HardeningChanges = changes
+	return nil
+}
+
+// create databases and users
+func (this *FoolishInstaller) stepAccounts(state *InstallState) error {
+	if len(this.databases) == 0 && len(this.users) == 0 {
+		return nil
+	}
+
+	// server may be stopped after reboot when resuming
+	if !this.dryRun && this.probeServer(0, state.DataDir()) != nil {
+		err := this.stepStart(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	var sqlList = []string{}
+	for _, database := range this.databases {
+		this.log("creating database '" + database.Name + "' ...")
+		var sql = "CREATE DATABASE IF NOT EXISTS " + mysqlclient.QuoteIdentifier(database.Name)
+		if len(database.Charset) > 0 {
+			sql += " DEFAULT CHARACTER SET " + database.Charset
+		}
+		if len(database.Collation) > 0 {
+			sql += " DEFAULT COLLATE " + database.Collation
+		}
+		sqlList = append(sqlList, sql)
+	}
+
+	for _, user := range this.users {
+		if len(user.Host) == 0 {
+			user.Host = DefaultUserHost
+		}
+		this.log("creating user '" + user.Name + "'@'" + user.Host + "' ...")
+		var password = user.Password
+		if len(user.Password) == 0 {
+			if this.dryRun {
+				password = "<generated password>"
+			} else {
+				generatedPassword, err := this.generatePassword()
+				if err != nil {
+					return errors.New("generate password of user '" + user.Name + "' failed: " + err.Error())
+				}
+				password = generatedPassword
+				user.Password = generatedPassword
+				user.PasswordGenerated = true
+			}
+		} else if this.dryRun {
+			password = "<password>"
+		}
+		var account = mysqlclient.QuoteString(user.Name) + "@" + mysqlclient.QuoteString(user.Host)
+		sqlList = append(sqlList, "CREATE USER IF NOT EXISTS "+account+" IDENTIFIED BY "+mysqlclient.QuoteString(password))
+		for _, grant := range user.Grants {
+			var privileges = "ALL PRIVILEGES"
+			if len(grant.Privileges) > 0 {
+				privileges = strings.ToUpper(strings.Join(grant.Privileges, ", "))
+			}
+			var target = "*.*"
+			if grant.Database != "*" {
+				target = mysqlclient.QuoteIdentifier(grant.Database) + ".*"
+			}
+			sqlList = append(sqlList, "GRANT "+privileges+" ON "+target+" TO "+account)
+		}
+	}
+
+	// generated passwords are needed to resume
+	state.Databases = this.databases
+	state.Users = this.users
+
+	err := this.execSQL(this.password, sqlList, false)
+	if err != nil {
+		return errors.New("execute sql failed: " + err.Error())
+	}
+	return nil
+}
+
+// execute sql statements as root with built-in client, errors returned by server are kept as they are
+func (this *FoolishInstaller) execSQL(password string, sqlList []string, expiredPassword bool) error {
+	if this.dryRun {
+		var comment = "executed with built-in client"
+		if expiredPassword {
+			comment += ", connected with expired password"
+		}
+		this.plan.Add(&PlanAction{Type: PlanActionSQL, Name: "root@localhost", Path: this.socketFile(), Content: strings.Join(sqlList, ";\n") + ";\n", Comment: comment})
+		return nil
+	}
+
+	conn, err := this.connect(password, expiredPassword)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for _, sql := range sqlList {
+		err = conn.Exec(this.ctx, sql)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// connect to local server as root with unix socket
+// password is ignored by server after root@localhost is switched to auth_socket
+func (this *FoolishInstaller) connect(password string, expiredPassword bool) (*mysqlclient.Conn, error) {
+	conn, err := mysqlclient.Connect(this.ctx, &mysqlclient.Config{
+		Network:              "unix",
+		Address:              this.socketFile(),
+		User:                 "root",
+		Password:             password,
+		AllowExpiredPassword: expiredPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// backslashes in quoted strings are escape characters
+	if conn.NoBackslashEscapes() {
+		err = conn.Exec(this.ctx, "SET SESSION sql_mode = REPLACE(@@SESSION.sql_mode, 'NO_BACKSLASH_ESCAPES', '')")
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// remove temporary dir, and link 'mysql' client command
+func (this *FoolishInstaller) stepLink(state *InstallState) error {
+	// remove temporary directory
+	if len(state.ExtractDir) > 0 {
+		_, err := os.Stat(state.ExtractDir)
+		if err == nil || this.dryRun {
+			_ = this.remove(state.ExtractDir)
+		}
+	}
+
+	// create link to 'mysql' client command
+	var clientExe = "/usr/local/bin/mysql"
+	_, err := os.Stat(clientExe)
+	if err != nil && os.IsNotExist(err) {
+		err = this.symlink(state.BaseDir+"/bin/mysql", clientExe)
+		if err == nil {
+			this.log("created symbolic link '" + clientExe + "' to '" + state.BaseDir + "/bin/mysql'")
+		} else {
+			this.warn("failed to create symbolic link '" + clientExe + "' to '" + state.BaseDir + "/bin/mysql': " + err.Error())
+		}
+	}
+	return nil
+}
+
+// register systemd service
+// this is not required, so we ignore all errors
+func (this *FoolishInstaller) stepService(state *InstallState) error {
+	err := this.installService(state.BaseDir)
+	if err != nil {
+		this.warn("install service failed: " + err.Error())
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"encoding/json"
+	"foolishmysql/internal/installers"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateSteps(t *testing.T) {
+	if installers.ValidateSteps([]string{"preflight", "service"}) != nil {
+		t.Fatal("steps should be valid")
+	}
+	var err = installers.ValidateSteps([]string{"configure", "unknown"})
+	if err == nil {
+		t.Fatal("step 'unknown' should be invalid")
+	}
+	t.Log(err)
+}
+
+func TestFoolishInstaller_Resume(t *testing.T) {
+	var stateDir = t.TempDir()
+	var baseDir = t.TempDir() + "/mysql"
+
+	// all steps except 'link' and 'service' were completed before
+	var state = &installers.InstallState{
+		ArchivePath:    "/tmp/mysql-8.0.36-linux-glibc2.17-x86_64-minimal.tar.xz",
+		TargetDir:      baseDir,
+		Instance:       "resume",
+		Port:           33062,
+		Socket:         "/tmp/mysql-resume.sock",
+		BaseDir:        baseDir,
+		RootPassword:   "123456",
+		CompletedSteps: installers.AllSteps[:len(installers.AllSteps)-2],
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(stateDir+"/install-resume.json", data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reporter = newStepReporter()
+	var statuses = reporter.statuses
+	var installer = installers.NewFoolishInstaller(installers.WithReporter(reporter)).
+		WithInstance("resume").
+		WithStateDir(stateDir).
+		WithDryRun(true)
+	err = installer.Resume()
+	if err != nil {
+		if strings.Contains(err.Error(), "could not find") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+
+	for _, action := range installer.Plan().Actions {
+		if action.Type == installers.PlanActionExtract || action.Type == installers.PlanActionCommand {
+			t.Fatal("completed steps should not be run again:", action.Type, action.Path, action.Command)
+		}
+	}
+	if installer.Port() != 33062 || installer.Password() != "123456" || installer.BaseDir() != baseDir {
+		t.Fatal("options should be restored from state")
+	}
+	if statuses[installers.StepPreflight] != installers.StepStatusCompleted || statuses[installers.StepExtract] != installers.StepStatusSkipped || statuses[installers.StepService] != installers.StepStatusCompleted {
+		t.Fatal("unexpected step events:", statuses)
+	}
+	var result = installer.Result()
+	if result.Socket != "/tmp/mysql-resume.sock" || result.CredentialFile != baseDir+"/generated-password.txt" || result.ServiceName != "mysqld@resume.service" {
+		t.Fatal("unexpected result:", result)
+	}
+	t.Log(installer.Plan().String())
+
+	// only run some steps
+	installer = installers.NewFoolishInstaller().
+		WithInstance("resume").
+		WithStateDir(stateDir).
+		WithSteps([]string{installers.StepService}, nil).
+		WithDryRun(true)
+	err = installer.Resume()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range installer.Plan().Actions {
+		if action.Type != installers.PlanActionService && !(action.Type == installers.PlanActionFile && strings.HasSuffix(action.Path, "mysqld@resume.service")) {
+			t.Fatal("only 'service' step should be run, but got:", action.Type, action.Path)
+		}
+	}
+}
+
+// reporter to record status of steps
+type stepReporter struct {
+	*installers.ConsoleReporter
+
+	statuses map[installers.StepName]installers.StepStatus
+}
+
+func newStepReporter() *stepReporter {
+	return &stepReporter{
+		ConsoleReporter: installers.NewConsoleReporter(),
+		statuses:        map[installers.StepName]installers.StepStatus{},
+	}
+}
+
+func (this *stepReporter) StepStarted(step installers.StepName) {
+	this.statuses[step] = installers.StepStatusStarted
+}
+
+func (this *stepReporter) StepFinished(event *installers.StepEvent) {
+	this.statuses[event.Name] = event.Status
+}
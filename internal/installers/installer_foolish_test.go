@@ -23,3 +23,30 @@ func TestFoolishInstaller_Download(t *testing.T) {
 	}
 	t.Log("path:", path)
 }
+
+func TestFoolishInstaller_Instance(t *testing.T) {
+	var installer = installers.NewFoolishInstaller()
+	if installer.ConfigFile() != "/etc/my.cnf" || installer.ServiceName() != "mysqld.service" {
+		t.Fatal("invalid default config file or service name")
+	}
+
+	installer.WithInstance("test")
+	if installer.ConfigFile() != "/etc/mysql-test.cnf" {
+		t.Fatal("invalid config file:", installer.ConfigFile())
+	}
+	if installer.ServiceName() != "mysqld@test.service" {
+		t.Fatal("invalid service name:", installer.ServiceName())
+	}
+
+	for _, name := range []string{"test", "8.0", "app_1-a"} {
+		err := installers.ValidateInstanceName(name)
+		if err != nil {
+			t.Fatal("'"+name+"' should be valid:", err)
+		}
+	}
+	for _, name := range []string{"a/b", "", "..", "a b", "a\nb"} {
+		if installers.ValidateInstanceName(name) == nil {
+			t.Fatal("'" + name + "' should be invalid")
+		}
+	}
+}
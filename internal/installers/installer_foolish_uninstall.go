@@ -0,0 +1,331 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+	"foolishmysql/internal/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var myCnfBaseDirReg = regexp.MustCompile(`(?m)^\s*basedir\s*=\s*"?([^"\r\n]+?)"?\s*$`)
+var myCnfDataDirReg = regexp.MustCompile(`(?m)^\s*datadir\s*=\s*"?([^"\r\n]+?)"?\s*$`)
+var myCnfBackupReg = regexp.MustCompile(`\.\d{14}$`)
+var releaseDirReg = regexp.MustCompile(`-\d+\.\d+\.\d+$`)
+var mysqlGroupReg = regexp.MustCompile(`(?m)^mysql:`)
+
+// InstalledDirs read base dir and data dir of installed mysql from its config file
+func (this *FoolishInstaller) InstalledDirs() (baseDir string, dataDir string, err error) {
+	var myCnfFile = this.ConfigFile()
+	data, err := os.ReadFile(myCnfFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", errors.New("could not find config file '" + myCnfFile + "', mysql may be not installed")
+		}
+		return "", "", err
+	}
+
+	var matches = myCnfBaseDirReg.FindSubmatch(data)
+	if len(matches) == 0 {
+		return "", "", errors.New("could not find 'basedir' in '" + myCnfFile + "'")
+	}
+	baseDir = string(matches[1])
+
+	matches = myCnfDataDirReg.FindSubmatch(data)
+	if len(matches) > 0 {
+		dataDir = string(matches[1])
+	} else {
+		dataDir = baseDir + "/data"
+	}
+	return
+}
+
+// Uninstall stop server, remove service, files and symbolic links created by installer, and restore the backup of my.cnf
+// data dir, 'mysql' user and group will be deleted only if purgeData is true
+func (this *FoolishInstaller) Uninstall(purgeData bool) (removed []string, err error) {
+	baseDir, dataDir, err := this.InstalledDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	// stop server
+	this.log("stopping mysql ...")
+	var serviceName = this.ServiceName()
+	var serviceFile = "/etc/systemd/system/" + serviceName
+	systemctlExe, _ := exec.LookPath("systemctl")
+	_, serviceErr := os.Stat(serviceFile)
+	if len(systemctlExe) > 0 && serviceErr == nil {
+		_ = utils.NewTimeoutCmd(60*time.Second, systemctlExe, "stop", serviceName).Run()
+	}
+	err = this.stopMysqld(dataDir)
+	if err != nil {
+		return removed, errors.New("stop mysql failed: " + err.Error())
+	}
+	removed = append(removed, "stopped mysql server")
+
+	// service
+	if serviceErr == nil {
+		this.log("removing service '" + serviceName + "' ...")
+		if len(systemctlExe) > 0 {
+			var cmd = utils.NewTimeoutCmd(10*time.Second, systemctlExe, "disable", serviceName)
+			cmd.WithStderr()
+			err = cmd.Run()
+			if err != nil {
+				this.warn("disable service '" + serviceName + "' failed: " + cmd.Stderr())
+			} else {
+				removed = append(removed, "disabled service '"+serviceName+"'")
+			}
+		}
+		err = os.Remove(serviceFile)
+		if err != nil {
+			this.warn("remove service file '" + serviceFile + "' failed: " + err.Error())
+		} else {
+			removed = append(removed, "removed service file '"+serviceFile+"'")
+			if len(systemctlExe) > 0 {
+				_ = utils.NewTimeoutCmd(10*time.Second, systemctlExe, "daemon-reload").Run()
+			}
+		}
+	}
+
+	// client symbolic link, only if it points into our base dir
+	var clientExe = "/usr/local/bin/mysql"
+	linkTarget, linkErr := os.Readlink(clientExe)
+	if linkErr == nil && this.isSubDir(baseDir, linkTarget) {
+		err = os.Remove(clientExe)
+		if err != nil {
+			this.warn("remove symbolic link '" + clientExe + "' failed: " + err.Error())
+		} else {
+			removed = append(removed, "removed symbolic link '"+clientExe+"'")
+		}
+	}
+
+	// restore the latest backup of my.cnf
+	var myCnfFile = this.ConfigFile()
+	var latestBackup = this.latestConfigBackup()
+	if len(latestBackup) > 0 {
+		err = os.Rename(latestBackup, myCnfFile)
+		if err != nil {
+			this.warn("restore '" + latestBackup + "' failed: " + err.Error())
+		} else {
+			removed = append(removed, "restored '"+latestBackup+"' to '"+myCnfFile+"'")
+		}
+	} else {
+		err = os.Remove(myCnfFile)
+		if err != nil {
+			this.warn("remove '" + myCnfFile + "' failed: " + err.Error())
+		} else {
+			removed = append(removed, "removed '"+myCnfFile+"'")
+		}
+	}
+
+	// base dir may be a symbolic link to a release dir after upgrading, the link and the release dir are removed or kept together
+	var realBaseDir = baseDir
+	baseDirStat, err := os.Lstat(baseDir)
+	var baseDirIsLink = err == nil && baseDirStat.Mode()&os.ModeSymlink == os.ModeSymlink
+	if baseDirIsLink {
+		resolvedBaseDir, err := filepath.EvalSymlinks(baseDir)
+		if err == nil {
+			realBaseDir = resolvedBaseDir
+		}
+	}
+
+	// data dir inside base dir is kept unless purging data
+	var realDataDir = dataDir
+	resolvedDataDir, err := filepath.EvalSymlinks(dataDir)
+	if err == nil {
+		realDataDir = resolvedDataDir
+	}
+	var keepDataDir = !purgeData && this.isSubDir(realBaseDir, realDataDir)
+	_, err = os.Stat(realBaseDir + "/bin/mysqld")
+	if err != nil {
+		this.warn("'" + baseDir + "' does not look like a mysql base dir, skip removing it")
+	} else if keepDataDir {
+		entries, err := os.ReadDir(realBaseDir)
+		if err != nil {
+			return removed, err
+		}
+		for _, entry := range entries {
+			var path = realBaseDir + "/" + entry.Name()
+			if this.isSubDir(path, realDataDir) {
+				continue
+			}
+			err = os.RemoveAll(path)
+			if err != nil {
+				return removed, errors.New("remove '" + path + "' failed: " + err.Error())
+			}
+		}
+		removed = append(removed, "removed files in '"+realBaseDir+"' except data dir '"+dataDir+"'")
+		if baseDirIsLink {
+			this.log("symbolic link '" + baseDir + "' to '" + realBaseDir + "' is kept with data dir")
+		}
+	} else {
+		if baseDirIsLink {
+			err = os.Remove(baseDir)
+			if err != nil {
+				return removed, errors.New("remove symbolic link '" + baseDir + "' failed: " + err.Error())
+			}
+			removed = append(removed, "removed symbolic link '"+baseDir+"'")
+		}
+		err = os.RemoveAll(realBaseDir)
+		if err != nil {
+			return removed, errors.New("remove '" + realBaseDir + "' failed: " + err.Error())
+		}
+		removed = append(removed, "removed '"+realBaseDir+"'")
+	}
+
+	// other release dirs created by upgrading
+	for _, releaseDir := range this.releaseDirs(baseDir) {
+		if releaseDir == realBaseDir || (keepDataDir && this.isSubDir(releaseDir, realDataDir)) {
+			continue
+		}
+		err = os.RemoveAll(releaseDir)
+		if err != nil {
+			return removed, errors.New("remove '" + releaseDir + "' failed: " + err.Error())
+		}
+		removed = append(removed, "removed '"+releaseDir+"'")
+	}
+
+	// state file of installation
+	_ = os.Remove(this.StateFile())
+
+	if !purgeData {
+		return removed, nil
+	}
+
+	// data dir
+	_, err = os.Stat(dataDir)
+	if err == nil {
+		err = os.RemoveAll(dataDir)
+		if err != nil {
+			return removed, errors.New("remove data dir '" + dataDir + "' failed: " + err.Error())
+		}
+		removed = append(removed, "removed data dir '"+dataDir+"'")
+	}
+
+	// 'mysql' user and group may be used by other instances
+	var otherConfigs = this.otherConfigFiles()
+	if len(otherConfigs) > 0 {
+		this.warn("'mysql' user and group are kept, they are used by other installations: " + otherConfigs[0])
+		return removed, nil
+	}
+	err = this.deleteUserOrGroup([]string{"userdel", "deluser"}, "mysql")
+	if err != nil {
+		this.warn("delete user 'mysql' failed: " + err.Error())
+	} else {
+		removed = append(removed, "deleted user 'mysql'")
+	}
+
+	// group may be deleted with user
+	groupData, err := os.ReadFile("/etc/group")
+	if err == nil && mysqlGroupReg.Match(groupData) {
+		err = this.deleteUserOrGroup([]string{"groupdel", "delgroup"}, "mysql")
+		if err != nil {
+			this.warn("delete user group 'mysql' failed: " + err.Error())
+		} else {
+			removed = append(removed, "deleted user group 'mysql'")
+		}
+	}
+
+	return removed, nil
+}
+
+// find release dirs created by upgrading, such as '/usr/local/mysql-8.0.36'
+// dirs without marker of base dir, or used by other installations, may be base dirs of other instances, they are skipped
+func (this *FoolishInstaller) releaseDirs(baseDir string) []string {
+	var result = []string{}
+	var otherDirs = this.otherInstalledDirs()
+	matches, _ := filepath.Glob(baseDir + "-*")
+	for _, match := range matches {
+		if !releaseDirReg.MatchString(match) {
+			continue
+		}
+		_, err := os.Stat(match + "/bin/mysqld")
+		if err != nil {
+			continue
+		}
+		markerData, err := os.ReadFile(match + "/" + releaseMarkerFile)
+		if err != nil || strings.TrimSpace(string(markerData)) != baseDir {
+			this.warn("'" + match + "' was not created by upgrading '" + baseDir + "', skip removing it")
+			continue
+		}
+		var isUsed = false
+		for _, otherDir := range otherDirs {
+			if this.isSubDir(match, otherDir) {
+				this.warn("'" + match + "' is used by other installation, skip removing it")
+				isUsed = true
+				break
+			}
+		}
+		if !isUsed {
+			result = append(result, match)
+		}
+	}
+	return result
+}
+
+// base dirs and data dirs of other installations on this host, symbolic links are resolved
+func (this *FoolishInstaller) otherInstalledDirs() []string {
+	var result = []string{}
+	for _, configFile := range this.otherConfigFiles() {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			continue
+		}
+		for _, reg := range []*regexp.Regexp{myCnfBaseDirReg, myCnfDataDirReg} {
+			var matches = reg.FindSubmatch(data)
+			if len(matches) == 0 {
+				continue
+			}
+			var dir = string(matches[1])
+			result = append(result, dir)
+			realDir, err := filepath.EvalSymlinks(dir)
+			if err == nil && realDir != dir {
+				result = append(result, realDir)
+			}
+		}
+	}
+	return result
+}
+
+// find the latest backup of my.cnf, such as '/etc/my.cnf.20230102150405'
+func (this *FoolishInstaller) latestConfigBackup() string {
+	var myCnfFile = this.ConfigFile()
+	matches, _ := filepath.Glob(myCnfFile + ".*")
+	var backups = []string{}
+	for _, match := range matches {
+		if myCnfBackupReg.MatchString(match) {
+			backups = append(backups, match)
+		}
+	}
+	if len(backups) == 0 {
+		return ""
+	}
+	sort.Strings(backups)
+	return backups[len(backups)-1]
+}
+
+// config files of other installations on this host
+func (this *FoolishInstaller) otherConfigFiles() []string {
+	var result = []string{}
+	var myCnfFile = this.ConfigFile()
+	var candidates, _ = filepath.Glob("/etc/mysql-*.cnf")
+	if len(this.instance) > 0 {
+		candidates = append(candidates, "/etc/my.cnf")
+	}
+	for _, candidate := range candidates {
+		if candidate == myCnfFile {
+			continue
+		}
+		_, err := os.Stat(candidate)
+		if err == nil {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"foolishmysql/internal/installers"
+	"os"
+	"testing"
+)
+
+// create a fake mysql release dir, with marker of base dir if upgradedFrom is not empty
+func createUninstallRelease(t *testing.T, dir string, upgradedFrom string) {
+	err := os.MkdirAll(dir+"/bin", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/bin/mysqld", []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upgradedFrom) > 0 {
+		err = os.WriteFile(dir+"/.foolish-mysql-release", []byte(upgradedFrom+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// create config file of instance, it is removed after testing
+func createUninstallConfig(t *testing.T, instance string, baseDir string, dataDir string) {
+	var configFile = "/etc/mysql-" + instance + ".cnf"
+	err := os.WriteFile(configFile, []byte("[mysqld]\nbasedir=\""+baseDir+"\"\ndatadir=\""+dataDir+"\"\n"), 0644)
+	if err != nil {
+		t.Skip("could not create '" + configFile + "': " + err.Error())
+	}
+	t.Cleanup(func() {
+		_ = os.Remove(configFile)
+	})
+}
+
+func assertExists(t *testing.T, path string, shouldExist bool) {
+	_, err := os.Lstat(path)
+	if shouldExist && err != nil {
+		t.Fatal("'" + path + "' should be kept")
+	}
+	if !shouldExist && err == nil {
+		t.Fatal("'" + path + "' should be removed")
+	}
+}
+
+func TestFoolishInstaller_Uninstall_SymbolicBaseDir(t *testing.T) {
+	var dir = t.TempDir()
+	var baseDir = dir + "/mysql"
+	var dataDir = dir + "/data"
+	createUninstallRelease(t, baseDir+"-8.0.36", baseDir)
+	createUninstallRelease(t, baseDir+"-8.4.2", baseDir)
+	err := os.Symlink(baseDir+"-8.4.2", baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(dataDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createUninstallConfig(t, "uninstall-link", baseDir, dataDir)
+
+	removed, err := installers.NewFoolishInstaller().
+		WithInstance("uninstall-link").
+		WithStateDir(t.TempDir()).
+		Uninstall(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(removed)
+
+	// link and release dirs are removed together, data dir outside is kept
+	assertExists(t, baseDir, false)
+	assertExists(t, baseDir+"-8.4.2", false)
+	assertExists(t, baseDir+"-8.0.36", false)
+	assertExists(t, dataDir, true)
+}
+
+func TestFoolishInstaller_Uninstall_KeepDataDir(t *testing.T) {
+	var dir = t.TempDir()
+	var baseDir = dir + "/mysql"
+	createUninstallRelease(t, baseDir+"-8.0.36", baseDir)
+	createUninstallRelease(t, baseDir+"-8.4.2", baseDir)
+	err := os.Symlink(baseDir+"-8.4.2", baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(baseDir+"-8.4.2/bin/mysql", []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.MkdirAll(baseDir+"-8.4.2/data/mysql", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createUninstallConfig(t, "uninstall-keep", baseDir, baseDir+"/data")
+
+	_, err = installers.NewFoolishInstaller().
+		WithInstance("uninstall-keep").
+		WithStateDir(t.TempDir()).
+		Uninstall(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// link is kept with data dir in the release dir, binaries and the old release are removed
+	assertExists(t, baseDir, true)
+	assertExists(t, baseDir+"/data/mysql", true)
+	assertExists(t, baseDir+"-8.4.2/bin", false)
+	assertExists(t, baseDir+"-8.0.36", false)
+}
+
+func TestFoolishInstaller_Uninstall_NeighbourInstance(t *testing.T) {
+	var dir = t.TempDir()
+	var baseDir = dir + "/mysql"
+	createUninstallRelease(t, baseDir, "")
+	err := os.Mkdir(baseDir+"/data", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createUninstallConfig(t, "uninstall-main", baseDir, baseDir+"/data")
+
+	// instance named '8.0.36' installed in default base dir, without marker
+	var neighbourDir = baseDir + "-8.0.36"
+	createUninstallRelease(t, neighbourDir, "")
+	err = os.Mkdir(neighbourDir+"/data", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// instance referencing a marked release dir
+	var otherDir = baseDir + "-8.4.2"
+	createUninstallRelease(t, otherDir, baseDir)
+	createUninstallConfig(t, "uninstall-other", otherDir, otherDir+"/data")
+
+	_, err = installers.NewFoolishInstaller().
+		WithInstance("uninstall-main").
+		WithStateDir(t.TempDir()).
+		Uninstall(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertExists(t, baseDir+"/bin", false)
+	assertExists(t, baseDir+"/data", true)
+	assertExists(t, neighbourDir+"/bin/mysqld", true)
+	assertExists(t, neighbourDir+"/data", true)
+	assertExists(t, otherDir+"/bin/mysqld", true)
+}
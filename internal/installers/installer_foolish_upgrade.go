@@ -0,0 +1,385 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+	"foolishmysql/internal/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultUpgradeTimeout time to wait for the new server, data dictionary upgrade may take a while on big data dirs
+	DefaultUpgradeTimeout = 10 * time.Minute
+
+	// file in release dirs created by upgrading, it contains the base dir linked to them
+	releaseMarkerFile = ".foolish-mysql-release"
+)
+
+var mysqldVersionReg = regexp.MustCompile(`\bVer\s+(\d+\.\d+\.\d+)`)
+
+// UpgradeResult result of a successful upgrade
+type UpgradeResult struct {
+	OldVersion string
+	NewVersion string
+	BaseDir    string // base dir in my.cnf, a symbolic link to the new release dir after upgrading
+	ReleaseDir string // dir of the new release
+	OldDir     string // dir of the old release, kept for switching back manually
+}
+
+// InstalledVersion get version of installed mysqld
+func (this *FoolishInstaller) InstalledVersion() (string, error) {
+	baseDir, _, err := this.InstalledDirs()
+	if err != nil {
+		return "", err
+	}
+	return this.mysqldVersion(baseDir)
+}
+
+// Upgrade upgrade installed mysql to the release in archive file
+// the new release is extracted beside current base dir, then base dir is switched to it with a symbolic link,
+// data dir is kept and upgraded by the new mysqld on startup,
+// all changes will be rolled back and the old server will be started again if the new server fails to start, see RolledBack()
+func (this *FoolishInstaller) Upgrade(archivePath string, timeout time.Duration) (*UpgradeResult, error) {
+	this.journal = NewJournal()
+	this.rolledBack = nil
+	defer func() {
+		this.journal = nil
+	}()
+
+	result, err := this.upgrade(archivePath, timeout)
+	if err != nil {
+		this.rollback()
+		return nil, err
+	}
+	this.log("finished")
+	return result, nil
+}
+
+func (this *FoolishInstaller) upgrade(archivePath string, timeout time.Duration) (*UpgradeResult, error) {
+	baseDir, dataDir, err := this.InstalledDirs()
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultUpgradeTimeout
+	}
+
+	// read options from my.cnf, they are used to check the new server like installing
+	myCnfData, err := os.ReadFile(this.ConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	var mysqldOptions = parseMysqldOptions(myCnfData)
+	port, _ := strconv.Atoi(strings.Trim(mysqldOptions["port"], "\"'"))
+	if port > 0 {
+		this.port = port
+	}
+	var socket = strings.Trim(mysqldOptions["socket"], "\"'")
+	if len(socket) > 0 {
+		this.socket = socket
+	}
+	this.myCnfOptions = mysqldOptions
+
+	oldVersion, err := this.mysqldVersion(baseDir)
+	if err != nil {
+		return nil, errors.New("read version of installed mysql failed: " + err.Error())
+	}
+	this.log("installed version: v" + oldVersion)
+
+	// check installer file
+	this.log("checking installer file ...")
+	_, err = utils.DetectArchiveFormat(archivePath)
+	if err != nil {
+		return nil, errors.New("invalid installer file '" + archivePath + "': " + err.Error())
+	}
+	err = this.verifyFile(archivePath)
+	if err != nil {
+		return nil, errors.New("verify installer file '" + archivePath + "' failed: " + err.Error())
+	}
+	err = this.checkArchivePlatform(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// base dir may be a symbolic link created by last upgrade
+	var linkedDir = ""
+	stat, err := os.Lstat(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Mode()&os.ModeSymlink == os.ModeSymlink {
+		linkedDir, err = filepath.EvalSymlinks(baseDir)
+		if err != nil {
+			return nil, errors.New("read symbolic link '" + baseDir + "' failed: " + err.Error())
+		}
+	}
+
+	// extract new release beside base dir
+	var parentDir = filepath.Dir(baseDir)
+	var extractDir = parentDir + "/.foolish-mysql-tmp"
+	_, err = os.Stat(extractDir)
+	if err == nil {
+		err = this.removeAll(extractDir)
+		if err != nil {
+			return nil, errors.New("clean temporary directory '" + extractDir + "' failed: " + err.Error())
+		}
+	}
+	err = this.mkdir(extractDir, 0777)
+	if err != nil {
+		return nil, errors.New("create temporary directory '" + extractDir + "' failed: " + err.Error())
+	}
+
+	this.log("extracting installer file ...")
+	err = this.extractArchive(archivePath, extractDir)
+	if err != nil {
+		return nil, errors.New("extract installer file '" + archivePath + "' failed: " + err.Error())
+	}
+	matches, err := filepath.Glob(extractDir + "/mysql-*")
+	if err != nil || len(matches) == 0 {
+		return nil, errors.New("could not find mysql installer directory from '" + extractDir + "'")
+	}
+
+	// check version
+	newVersion, err := this.mysqldVersion(matches[0])
+	if err != nil {
+		return nil, errors.New("read version of new mysql failed: " + err.Error())
+	}
+	if utils.VersionCompare(newVersion, oldVersion) <= 0 {
+		return nil, errors.New("new version v" + newVersion + " should be newer than installed version v" + oldVersion + ", downgrading is not supported")
+	}
+	this.log("upgrading from v" + oldVersion + " to v" + newVersion + " ...")
+
+	var releaseDir = baseDir + "-" + newVersion
+	_, err = os.Lstat(releaseDir)
+	if err == nil {
+		return nil, errors.New("release dir '" + releaseDir + "' already exists, please remove it first")
+	}
+	err = os.Rename(matches[0], releaseDir)
+	if err != nil {
+		return nil, errors.New("move '" + matches[0] + "' to '" + releaseDir + "' failed: " + err.Error())
+	}
+	this.journal.Add("remove dir '"+releaseDir+"'", func() error {
+		return os.RemoveAll(releaseDir)
+	})
+	_ = this.removeAll(extractDir)
+	err = this.markReleaseDir(releaseDir, baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// stop server
+	this.log("stopping mysql ...")
+	var useService = this.isServiceActive()
+	err = this.stopInstalledServer(useService, dataDir)
+	if err != nil {
+		return nil, errors.New("stop mysql failed: " + err.Error())
+	}
+	this.journal.Add("start mysql server v"+oldVersion, func() error {
+		_, err := this.startInstalledServer(useService, dataDir, oldVersion, timeout)
+		return err
+	})
+
+	// move real base dir to the side, so base dir can be a symbolic link
+	var oldDir = linkedDir
+	if len(linkedDir) == 0 {
+		oldDir = baseDir + "-" + oldVersion
+		_, err = os.Lstat(oldDir)
+		if err == nil {
+			return nil, errors.New("dir '" + oldDir + "' already exists, please remove it first")
+		}
+		err = this.rename(baseDir, oldDir)
+		if err != nil {
+			return nil, errors.New("move '" + baseDir + "' to '" + oldDir + "' failed: " + err.Error())
+		}
+		err = this.markReleaseDir(oldDir, baseDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// data dir inside base dir goes to the new release
+	if this.isSubDir(baseDir, dataDir) {
+		var relDataDir, _ = filepath.Rel(baseDir, dataDir)
+		var oldDataDir = oldDir + "/" + relDataDir
+		var newDataDir = releaseDir + "/" + relDataDir
+		err = this.mkdir(filepath.Dir(newDataDir), 0755)
+		if err != nil {
+			return nil, errors.New("create dir '" + filepath.Dir(newDataDir) + "' failed: " + err.Error())
+		}
+		err = this.rename(oldDataDir, newDataDir)
+		if err != nil {
+			return nil, errors.New("move data dir '" + oldDataDir + "' to '" + newDataDir + "' failed: " + err.Error())
+		}
+
+		// keep generated password with data
+		passwordData, err := os.ReadFile(this.credentialFile(oldDir))
+		if err == nil {
+			_ = this.writeFile(this.credentialFile(releaseDir), passwordData, 0600)
+		}
+	}
+
+	// switch base dir
+	this.log("switching '" + baseDir + "' to '" + releaseDir + "' ...")
+	if len(linkedDir) == 0 {
+		err = this.symlink(releaseDir, baseDir)
+	} else {
+		err = this.replaceSymlink(releaseDir, baseDir)
+	}
+	if err != nil {
+		return nil, errors.New("link '" + baseDir + "' to '" + releaseDir + "' failed: " + err.Error())
+	}
+
+	// start new server, and check it
+	this.log("starting mysql v" + newVersion + " ...")
+	stopNewServer, err := this.startInstalledServer(useService, dataDir, newVersion, timeout)
+	if stopNewServer != nil {
+		this.journal.Add("stop mysql server v"+newVersion, stopNewServer)
+	}
+	if err != nil {
+		return nil, errors.New("start mysql v" + newVersion + " failed: " + err.Error())
+	}
+
+	return &UpgradeResult{
+		OldVersion: oldVersion,
+		NewVersion: newVersion,
+		BaseDir:    baseDir,
+		ReleaseDir: releaseDir,
+		OldDir:     oldDir,
+	}, nil
+}
+
+// mark release dir as created by upgrading of base dir, only marked release dirs are removed by uninstalling
+func (this *FoolishInstaller) markReleaseDir(releaseDir string, baseDir string) error {
+	var markerFile = releaseDir + "/" + releaseMarkerFile
+	err := this.writeFile(markerFile, []byte(baseDir+"\n"), 0644)
+	if err != nil {
+		return errors.New("create '" + markerFile + "' failed: " + err.Error())
+	}
+	return nil
+}
+
+// read version from 'mysqld --version'
+func (this *FoolishInstaller) mysqldVersion(baseDir string) (string, error) {
+	var cmd = this.command(baseDir+"/bin/mysqld", "--version").WithTimeout(30 * time.Second)
+	cmd.WithStdout()
+	cmd.WithStderr()
+	err := cmd.Run()
+	if err != nil {
+		return "", errors.New(err.Error() + ": " + cmd.Stderr())
+	}
+	var matches = mysqldVersionReg.FindStringSubmatch(cmd.Stdout())
+	if len(matches) == 0 {
+		return "", errors.New("unexpected output '" + cmd.Stdout() + "'")
+	}
+	return matches[1], nil
+}
+
+// replace target of symbolic link atomically
+func (this *FoolishInstaller) replaceSymlink(target string, link string) error {
+	oldTarget, err := os.Readlink(link)
+	if err != nil {
+		return err
+	}
+	var swap = func(target string) error {
+		var tmpLink = link + ".foolish-mysql-tmp"
+		_ = os.Remove(tmpLink)
+		err := os.Symlink(target, tmpLink)
+		if err != nil {
+			return err
+		}
+		return os.Rename(tmpLink, link)
+	}
+	err = swap(target)
+	if err != nil {
+		return err
+	}
+	this.journal.Add("link '"+link+"' back to '"+oldTarget+"'", func() error {
+		return swap(oldTarget)
+	})
+	return nil
+}
+
+// check whether installed server is managed by systemd
+func (this *FoolishInstaller) isServiceActive() bool {
+	systemctlExe, err := exec.LookPath("systemctl")
+	if err != nil {
+		return false
+	}
+	return this.command(systemctlExe, "is-active", "--quiet", this.ServiceName()).WithTimeout(10*time.Second).Run() == nil
+}
+
+// stop installed server with systemd, or signals
+func (this *FoolishInstaller) stopInstalledServer(useService bool, dataDir string) error {
+	if useService {
+		var cmd = this.command("systemctl", "stop", this.ServiceName()).WithTimeout(120 * time.Second)
+		cmd.WithStderr()
+		err := cmd.Run()
+		if err != nil {
+			return errors.New("stop service failed: " + cmd.Stderr())
+		}
+	}
+	return this.stopMysqld(dataDir)
+}
+
+// start installed server and wait until it serves the expected version
+// the returned function stops the started server
+func (this *FoolishInstaller) startInstalledServer(useService bool, dataDir string, version string, timeout time.Duration) (stop func() error, err error) {
+	var safeCmd *utils.Cmd
+	if useService {
+		var cmd = this.command("systemctl", "start", this.ServiceName()).WithTimeout(120 * time.Second)
+		cmd.WithStderr()
+		err = cmd.Run()
+		if err != nil {
+			return nil, errors.New("start service failed: " + cmd.Stderr())
+		}
+		stop = func() error {
+			return this.stopInstalledServer(true, dataDir)
+		}
+	} else {
+		baseDir, _, err := this.InstalledDirs()
+		if err != nil {
+			return nil, err
+		}
+		safeCmd = utils.NewCmd(baseDir+"/bin/mysqld_safe", "--defaults-file="+this.ConfigFile(), "--user=mysql")
+		err = safeCmd.Start()
+		if err != nil {
+			return nil, errors.New("start failed '" + safeCmd.String() + "': " + err.Error())
+		}
+		stop = func() error {
+			return this.stopServer(safeCmd, dataDir)
+		}
+	}
+
+	// waiting for startup, server started by systemd is checked without mysqld_safe
+	err = this.waitForServer(safeCmd, dataDir, version, timeout)
+	return stop, err
+}
+
+// read options in [mysqld] section of my.cnf, '-' in names is replaced with '_'
+func parseMysqldOptions(data []byte) map[string]string {
+	var options = map[string]string{}
+	var inSection = false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' || line[0] == '!' {
+			continue
+		}
+		if line[0] == '[' {
+			inSection = strings.ToLower(strings.Trim(line, "[] \t")) == "mysqld"
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		options[strings.ReplaceAll(strings.TrimSpace(key), "-", "_")] = strings.TrimSpace(value)
+	}
+	return options
+}
@@ -0,0 +1,29 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"foolishmysql/internal/installers"
+	"testing"
+	"time"
+)
+
+func TestFoolishInstaller_Upgrade_NotInstalled(t *testing.T) {
+	var installer = installers.NewFoolishInstaller()
+	installer.WithInstance("not-installed-upgrade")
+
+	_, err := installer.InstalledVersion()
+	if err == nil {
+		t.Fatal("should fail without installation")
+	}
+	t.Log(err)
+
+	_, err = installer.Upgrade("mysql-8.0.40-linux-glibc2.17-x86_64-minimal.tar.xz", 1*time.Second)
+	if err == nil {
+		t.Fatal("should fail without installation")
+	}
+	t.Log(err)
+	if len(installer.RolledBack()) > 0 {
+		t.Fatal("nothing should be rolled back")
+	}
+}
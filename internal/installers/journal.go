@@ -0,0 +1,59 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"errors"
+)
+
+// JournalEntry a mutating step made by installer, with the function to undo it
+type JournalEntry struct {
+	Description string // description of undo, such as "remove file '/etc/my.cnf'"
+	Undo        func() error
+}
+
+// Journal undo journal of mutating steps, used to roll back a failed installation
+type Journal struct {
+	entries []*JournalEntry
+}
+
+func NewJournal() *Journal {
+	return &Journal{}
+}
+
+// Add record undo function of a mutating step, nil journal is ignored
+func (this *Journal) Add(description string, undo func() error) {
+	if this == nil {
+		return
+	}
+	this.entries = append(this.entries, &JournalEntry{
+		Description: description,
+		Undo:        undo,
+	})
+}
+
+// Len get count of recorded entries
+func (this *Journal) Len() int {
+	if this == nil {
+		return 0
+	}
+	return len(this.entries)
+}
+
+// Rollback undo recorded steps in reverse order, failed undo will not stop others
+func (this *Journal) Rollback() (rolledBack []string, failures []error) {
+	if this == nil {
+		return
+	}
+	for i := len(this.entries) - 1; i >= 0; i-- {
+		var entry = this.entries[i]
+		err := entry.Undo()
+		if err != nil {
+			failures = append(failures, errors.New(entry.Description+" failed: "+err.Error()))
+		} else {
+			rolledBack = append(rolledBack, entry.Description)
+		}
+	}
+	this.entries = nil
+	return
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"errors"
+	"foolishmysql/internal/installers"
+	"strings"
+	"testing"
+)
+
+func TestJournal_Rollback(t *testing.T) {
+	var journal = installers.NewJournal()
+	var undone = []string{}
+	for _, name := range []string{"a", "b", "c"} {
+		var n = name
+		journal.Add("undo "+n, func() error {
+			undone = append(undone, n)
+			if n == "b" {
+				return errors.New("failure of b")
+			}
+			return nil
+		})
+	}
+
+	rolledBack, failures := journal.Rollback()
+	if strings.Join(undone, ",") != "c,b,a" {
+		t.Fatal("should be undone in reverse order, got", undone)
+	}
+	if strings.Join(rolledBack, ",") != "undo c,undo a" {
+		t.Fatal("invalid rolled back steps:", rolledBack)
+	}
+	if len(failures) != 1 || failures[0].Error() != "undo b failed: failure of b" {
+		t.Fatal("invalid failures:", failures)
+	}
+	if journal.Len() != 0 {
+		t.Fatal("journal should be empty after rollback")
+	}
+
+	// nil journal
+	var nilJournal *installers.Journal
+	nilJournal.Add("nothing", func() error { return nil })
+	if nilJournal.Len() != 0 {
+		t.Fatal("nil journal should ignore entries")
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"bytes"
+	"errors"
+	"foolishmysql/internal/utils"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultMirrorPath default path of packages on mirror, same as the layout of mysql CDN
+const DefaultMirrorPath = "MySQL-${major}/${package}"
+
+var mirrorPackageReg = regexp.MustCompile(`mysql-(\d+\.\d+\.\d+)-linux-`)
+
+// PackageInfo package to download
+type PackageInfo struct {
+	Version string // 8.0.36
+	Major   string // 8.0
+	Arch    string // x86_64, aarch64
+	Glibc   string // 2.17, 2.28
+	Flavour Flavour
+	Name    string // mysql-8.0.36-linux-glibc2.28-x86_64.tar.xz
+}
+
+// Mirror custom mirror of mysql packages, can be a remote url or a local directory
+//
+// the mirror can contain a templated path with placeholders:
+// ${version}, ${major}, ${arch}, ${glibc}, ${flavour} and ${package},
+// if there is no placeholder, the DefaultMirrorPath will be appended
+type Mirror struct {
+	template string
+	isLocal  bool
+}
+
+func NewMirror(mirror string) (*Mirror, error) {
+	mirror = strings.TrimSpace(mirror)
+	if len(mirror) == 0 {
+		return nil, errors.New("mirror should not be empty")
+	}
+
+	var isLocal = false
+	if strings.HasPrefix(mirror, "file://") {
+		isLocal = true
+		mirror = strings.TrimPrefix(mirror, "file://")
+	} else if strings.HasPrefix(mirror, "http://") || strings.HasPrefix(mirror, "https://") {
+		_, err := url.Parse(mirror)
+		if err != nil {
+			return nil, errors.New("invalid mirror url '" + mirror + "': " + err.Error())
+		}
+	} else {
+		isLocal = true
+	}
+
+	if isLocal {
+		absMirror, err := filepath.Abs(mirror)
+		if err != nil {
+			return nil, errors.New("invalid mirror dir '" + mirror + "': " + err.Error())
+		}
+		mirror = absMirror
+	}
+
+	if !strings.Contains(mirror, "${") {
+		mirror = strings.TrimRight(mirror, "/") + "/" + DefaultMirrorPath
+	}
+
+	return &Mirror{
+		template: mirror,
+		isLocal:  isLocal,
+	}, nil
+}
+
+// IsLocal check whether the mirror is a local directory
+func (this *Mirror) IsLocal() bool {
+	return this.isLocal
+}
+
+// PackageURL build url or local path of the package
+func (this *Mirror) PackageURL(pkg *PackageInfo) string {
+	return strings.NewReplacer(
+		"${version}", pkg.Version,
+		"${major}", pkg.Major,
+		"${arch}", pkg.Arch,
+		"${glibc}", pkg.Glibc,
+		"${flavour}", pkg.Flavour,
+		"${package}", pkg.Name,
+	).Replace(this.template)
+}
+
+// LatestVersion find the latest version of the series available on the mirror
+// series can be empty for the latest version of all series,
+// packageName is used to check whether the package of the version exists,
+// fetch is used to read index page of remote mirror
+func (this *Mirror) LatestVersion(series string, packageName func(version string) string, fetch func(indexURL string) ([]byte, error)) (string, error) {
+	var indexData []byte
+	var indexURL = this.indexURL(series)
+	if len(indexURL) == 0 {
+		return "", errors.New("could not list versions on mirror '" + this.template + "', please specify a concrete version")
+	}
+	if this.isLocal {
+		var names = []string{}
+		_ = filepath.WalkDir(indexURL, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				names = append(names, d.Name())
+			}
+			return nil
+		})
+		indexData = []byte(strings.Join(names, "\n"))
+	} else {
+		data, err := fetch(indexURL)
+		if err != nil {
+			return "", errors.New("read index of mirror '" + indexURL + "' failed: " + err.Error())
+		}
+		indexData = data
+	}
+
+	var latestVersion = ""
+	for _, match := range mirrorPackageReg.FindAllSubmatch(indexData, -1) {
+		var version = string(match[1])
+		if len(series) > 0 && MajorVersion(version) != series {
+			continue
+		}
+		if !bytes.Contains(indexData, []byte(packageName(version))) {
+			continue
+		}
+		if utils.VersionCompare(latestVersion, version) < 0 {
+			latestVersion = version
+		}
+	}
+	if len(latestVersion) == 0 {
+		if len(series) > 0 {
+			return "", errors.New("could not find any release of mysql series '" + series + "' on mirror")
+		}
+		return "", errors.New("could not find any release on mirror")
+	}
+	return latestVersion, nil
+}
+
+// url or local path of the directory containing packages of the series
+// if the directory is not determinable, an empty string will be returned
+func (this *Mirror) indexURL(series string) string {
+	var index = strings.LastIndex(this.template, "/")
+	if index < 0 {
+		return ""
+	}
+	var dir = this.template[:index]
+	if len(series) > 0 {
+		dir = strings.ReplaceAll(dir, "${major}", series)
+	}
+	if strings.Contains(dir, "${") {
+		if this.isLocal {
+			// scan recursively from the directory before the first placeholder
+			return filepath.Dir(dir[:strings.Index(dir, "${")] + "_")
+		}
+		return ""
+	}
+	if this.isLocal {
+		return dir
+	}
+	return dir + "/"
+}
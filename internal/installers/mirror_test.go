@@ -0,0 +1,207 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"foolishmysql/internal/installers"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestMirror(t *testing.T, dir string, versions []string) {
+	for _, version := range versions {
+		for _, platform := range []string{"glibc2.17-x86_64", "glibc2.28-aarch64"} {
+			writeTestMirrorFile(t, dir+"/MySQL-"+installers.MajorVersion(version)+"/mysql-"+version+"-linux-"+platform+"-minimal.tar.xz")
+		}
+	}
+}
+
+func writeTestMirrorFile(t *testing.T, file string) {
+	err := os.MkdirAll(filepath.Dir(file), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content = []byte("mysql " + filepath.Base(file))
+	err = os.WriteFile(file, content, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum = sha256.Sum256(content)
+	err = os.WriteFile(file+".sha256", []byte(hex.EncodeToString(sum[:])+"  "+filepath.Base(file)+"\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMirror_PackageURL(t *testing.T) {
+	mirror, err := installers.NewMirror("https://mirror.example.com/mysql/${major}/${arch}/mysql-${version}-linux-glibc${glibc}-${arch}.tar.xz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var url = mirror.PackageURL(&installers.PackageInfo{
+		Version: "8.0.36",
+		Major:   "8.0",
+		Arch:    "aarch64",
+		Glibc:   "2.28",
+	})
+	if url != "https://mirror.example.com/mysql/8.0/aarch64/mysql-8.0.36-linux-glibc2.28-aarch64.tar.xz" {
+		t.Fatal("invalid url:", url)
+	}
+
+	mirror, err = installers.NewMirror("https://mirror.example.com/mysql/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(mirror.PackageURL(&installers.PackageInfo{Version: "8.0.36", Major: "8.0", Name: "mysql-8.0.36-linux-glibc2.28-x86_64.tar.xz"}))
+}
+
+func TestFoolishInstaller_Download_LocalMirror(t *testing.T) {
+	var mirrorDir = t.TempDir()
+	writeTestMirror(t, mirrorDir, []string{"8.0.35", "8.0.36", "8.4.0"})
+
+	mirror, err := installers.NewMirror(mirrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithMirror(mirror).
+		WithVersion("8.0").
+		Download()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "mysql-8.0.36-linux-glibc2.17-x86_64-minimal.tar.xz" {
+		t.Fatal("invalid path:", path)
+	}
+
+	// tampered file
+	err = os.WriteFile(path, []byte("tampered"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithMirror(mirror).
+		WithVersion("8.0.36").
+		Download()
+	if err == nil {
+		t.Fatal("tampered file should not pass verification")
+	}
+	t.Log(err)
+
+	// no checksum files
+	err = os.Remove(filepath.Join(mirrorDir, "MySQL-8.0", "mysql-8.0.35-linux-glibc2.17-x86_64-minimal.tar.xz.sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithMirror(mirror).
+		WithVersion("8.0.35").
+		Download()
+	if err == nil {
+		t.Fatal("file without checksum should be rejected")
+	}
+	t.Log(err)
+	_, err = installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithMirror(mirror).
+		WithVersion("8.0.35").
+		WithSkipChecksum(true).
+		Download()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not exist
+	_, err = installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithMirror(mirror).
+		WithVersion("8.0.99").
+		Download()
+	if err == nil {
+		t.Fatal("version should not exist")
+	}
+	t.Log(err)
+}
+
+func TestFoolishInstaller_Download_Platform(t *testing.T) {
+	var mirrorDir = t.TempDir()
+	writeTestMirror(t, mirrorDir, []string{"8.0.36"})
+
+	mirror, err := installers.NewMirror(mirrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := installers.NewFoolishInstaller().
+		WithPlatform("aarch64", "2.35").
+		WithMirror(mirror).
+		WithVersion("8.0.36").
+		Download()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(path) != "mysql-8.0.36-linux-glibc2.28-aarch64-minimal.tar.xz" {
+		t.Fatal("invalid path:", path)
+	}
+
+	// too old glibc
+	_, err = installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.12").
+		WithMirror(mirror).
+		WithVersion("8.0.36").
+		Download()
+	if err == nil {
+		t.Fatal("glibc 2.12 should not be supported")
+	}
+	t.Log(err)
+}
+
+func TestFoolishInstaller_Download_RemoteMirror(t *testing.T) {
+	var mirrorDir = t.TempDir()
+	writeTestMirror(t, mirrorDir, []string{"8.0.35", "8.0.36"})
+	var server = httptest.NewServer(http.FileServer(http.Dir(mirrorDir)))
+	defer server.Close()
+
+	mirror, err := installers.NewMirror(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// download into temporary dir
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Chdir(oldDir)
+	}()
+
+	path, err := installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithMirror(mirror).
+		WithVersion("8.0").
+		Download()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "mysql "+filepath.Base(path) || filepath.Base(path) != "mysql-8.0.36-linux-glibc2.17-x86_64-minimal.tar.xz" {
+		t.Fatal("invalid content:", string(data))
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"os"
+)
+
+// MySQLReleaseKeyURL where to get the public key of mysql release engineering
+const MySQLReleaseKeyURL = "https://repo.mysql.com/RPM-GPG-KEY-mysql-2023"
+
+// MySQLReleaseKeyFingerprint fingerprint of the public key in MySQLReleaseKeyURL
+const MySQLReleaseKeyFingerprint = "BCA43417C3B485DD128EC6D4B7B3B788A8D3785C"
+
+// public key of mysql release engineering bundled with installer, downloaded from MySQLReleaseKeyURL
+//
+//go:embed mysql_release_key.asc
+var mysqlReleaseKey []byte
+
+// MySQLReleaseKey get the bundled public key of mysql release engineering
+func MySQLReleaseKey() []byte {
+	return mysqlReleaseKey
+}
+
+// well-known locations of the mysql release public key, installed by mysql repository packages
+// they override the bundled key, because they are updated with system packages
+var mysqlReleaseKeyFiles = []string{
+	"/etc/pki/rpm-gpg/RPM-GPG-KEY-mysql-2023",
+	"/etc/pki/rpm-gpg/RPM-GPG-KEY-mysql-2022",
+	"/etc/pki/rpm-gpg/RPM-GPG-KEY-mysql",
+	"/usr/share/keyrings/mysql-apt-config.gpg",
+	"/etc/apt/trusted.gpg.d/mysql-apt-config.gpg",
+}
+
+// read public key to verify signature
+// key file given by user is used first, then the installed release key, then the bundled one
+func (this *FoolishInstaller) readPublicKey() ([]byte, error) {
+	if len(this.publicKeyFile) > 0 {
+		data, err := os.ReadFile(this.publicKeyFile)
+		if err != nil {
+			return nil, errors.New("read public key file failed: " + err.Error())
+		}
+		return data, nil
+	}
+
+	for _, keyFile := range mysqlReleaseKeyFiles {
+		data, err := os.ReadFile(keyFile)
+		if err == nil && len(data) > 0 {
+			this.log("using public key '" + keyFile + "'")
+			return data, nil
+		}
+	}
+
+	if !bytes.Contains(mysqlReleaseKey, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		return nil, errors.New("bundled mysql release public key is missing, please download it from '" + MySQLReleaseKeyURL + "' and specify it with '--gpg-key'")
+	}
+	this.log("using bundled mysql release public key")
+	return mysqlReleaseKey, nil
+}
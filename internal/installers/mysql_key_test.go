@@ -0,0 +1,28 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"bytes"
+	"fmt"
+	"foolishmysql/internal/installers"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"testing"
+)
+
+func TestMySQLReleaseKey(t *testing.T) {
+	keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(installers.MySQLReleaseKey()))
+	if err != nil {
+		t.Fatal("bundled key should be an armored public key: " + err.Error())
+	}
+	if len(keyRing) != 1 {
+		t.Fatal("bundled key should contain one key, but got", len(keyRing))
+	}
+	var fingerprint = fmt.Sprintf("%X", keyRing[0].PrimaryKey.Fingerprint)
+	if fingerprint != installers.MySQLReleaseKeyFingerprint {
+		t.Fatal("fingerprint of bundled key should be '" + installers.MySQLReleaseKeyFingerprint + "', but got '" + fingerprint + "'")
+	}
+	for name := range keyRing[0].Identities {
+		t.Log(name)
+	}
+}
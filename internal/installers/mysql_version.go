@@ -0,0 +1,103 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	mysqlDownloadsPage = "https://dev.mysql.com/downloads/mysql/"
+	mysqlCDNURL        = "https://cdn.mysql.com/Downloads"
+	mysqlArchivesURL   = "https://cdn.mysql.com/archives"
+	mysqlArchivesPage  = "https://downloads.mysql.com/archives/community/"
+	mysqlSignatureURL  = "https://dev.mysql.com/downloads/gpg/?p=23&file="
+)
+
+var versionSelectorReg = regexp.MustCompile(`^(\d+\.\d+)(\.\d+)?$`)
+var md5Reg = regexp.MustCompile(`MD5:\s*(?:<[^>]*>\s*)*([a-fA-F0-9]{32})`)
+var signatureReg = regexp.MustCompile(`(?s)-----BEGIN PGP SIGNATURE-----.+?-----END PGP SIGNATURE-----`)
+
+// ParseVersionSelector parse version selector
+// '8.0.36' is a concrete release, '8.4' is a series which will be resolved to its latest release
+func ParseVersionSelector(selector string) (series string, version string, err error) {
+	selector = strings.TrimPrefix(strings.TrimSpace(selector), "v")
+	var matches = versionSelectorReg.FindStringSubmatch(selector)
+	if len(matches) == 0 {
+		return "", "", errors.New("invalid mysql version '" + selector + "', should be like '8.0.36' or '8.4'")
+	}
+	series = matches[1]
+	if len(matches[2]) > 0 {
+		version = selector
+	}
+	return
+}
+
+// MajorVersion get series of the version, e.g. '8.0' for '8.0.36'
+func MajorVersion(version string) string {
+	var pieces = strings.Split(version, ".")
+	if len(pieces) >= 2 {
+		return strings.Join(pieces[:2], ".")
+	}
+	return version
+}
+
+// VersionDownloadURLs build download urls for the package
+// current releases are on the CDN, older releases are moved to archives
+func VersionDownloadURLs(version string, packageName string) []string {
+	var series = MajorVersion(version)
+	return []string{
+		mysqlCDNURL + "/MySQL-" + series + "/" + packageName,
+		mysqlArchivesURL + "/mysql-" + series + "/" + packageName,
+	}
+}
+
+// find release version from mysql downloads page
+func findVersionInPage(data []byte, series string) string {
+	var reg *regexp.Regexp
+	if len(series) > 0 {
+		reg = regexp.MustCompile(`<h1>MySQL Community Server (` + regexp.QuoteMeta(series) + `\.\d+)`)
+	} else {
+		reg = regexp.MustCompile(`<h1>MySQL Community Server ([\d.]+) `)
+	}
+	var matches = reg.FindSubmatch(data)
+	if len(matches) > 0 {
+		return string(matches[1])
+	}
+	return ""
+}
+
+// VersionChecksumPages pages on which checksums of packages are published
+func VersionChecksumPages(version string) []string {
+	return []string{
+		mysqlDownloadsPage + MajorVersion(version) + ".html",
+		mysqlArchivesPage + "?tpl=platform&os=2&version=" + version,
+	}
+}
+
+// find published md5 checksum of the package from mysql downloads page
+func findMD5InPage(data []byte, packageName string) string {
+	var index = bytes.Index(data, []byte(packageName))
+	if index < 0 {
+		return ""
+	}
+
+	// md5 is in the same table row as the package name
+	var end = index + 4096
+	if end > len(data) {
+		end = len(data)
+	}
+	var matches = md5Reg.FindSubmatch(data[index:end])
+	if len(matches) > 0 {
+		return strings.ToLower(string(matches[1]))
+	}
+	return ""
+}
+
+// find armored signature from mysql signature page
+func findSignatureInPage(data []byte) []byte {
+	return signatureReg.Find(data)
+}
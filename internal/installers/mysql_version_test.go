@@ -0,0 +1,37 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"foolishmysql/internal/installers"
+	"testing"
+)
+
+func TestParseVersionSelector(t *testing.T) {
+	for _, selector := range []string{"8.0.36", "8.4", "v8.0.30", "8", "8.0.x", "latest"} {
+		series, version, err := installers.ParseVersionSelector(selector)
+		t.Log(selector, "=>", "series:", series, "version:", version, "err:", err)
+	}
+
+	series, version, err := installers.ParseVersionSelector("8.0.36")
+	if err != nil || series != "8.0" || version != "8.0.36" {
+		t.Fatal("parse '8.0.36' failed")
+	}
+
+	series, version, err = installers.ParseVersionSelector("8.4")
+	if err != nil || series != "8.4" || len(version) > 0 {
+		t.Fatal("parse '8.4' failed")
+	}
+}
+
+func TestVersionDownloadURLs(t *testing.T) {
+	for _, url := range installers.VersionDownloadURLs("8.0.30", "mysql-8.0.30-linux-glibc2.17-x86_64-minimal.tar.xz") {
+		t.Log(url)
+	}
+}
+
+func TestVersionChecksumPages(t *testing.T) {
+	for _, url := range installers.VersionChecksumPages("8.0.30") {
+		t.Log(url)
+	}
+}
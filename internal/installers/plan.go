@@ -0,0 +1,141 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+type PlanActionType = string
+
+const (
+	PlanActionDownload PlanActionType = "download"
+	PlanActionPackage  PlanActionType = "package"
+	PlanActionGroup    PlanActionType = "group"
+	PlanActionUser     PlanActionType = "user"
+	PlanActionDir      PlanActionType = "dir"
+	PlanActionExtract  PlanActionType = "extract"
+	PlanActionRename   PlanActionType = "rename"
+	PlanActionFile     PlanActionType = "file"
+	PlanActionCommand  PlanActionType = "command"
+	PlanActionRemove   PlanActionType = "remove"
+	PlanActionSymlink  PlanActionType = "symlink"
+	PlanActionService  PlanActionType = "service"
+	PlanActionSQL      PlanActionType = "sql"
+)
+
+// PlanAction a change to the system which will be made by installer
+type PlanAction struct {
+	Type    PlanActionType `json:"type"`
+	Name    string         `json:"name,omitempty"`    // package, user, group or service name, or account executing sql
+	Path    string         `json:"path,omitempty"`    // file, dir or symbolic link
+	Target  string         `json:"target,omitempty"`  // target of symbolic link, or new path of renaming
+	Mode    string         `json:"mode,omitempty"`    // permission of file or dir, such as '0644'
+	Content string         `json:"content,omitempty"` // content of file, input of command, or sql statements
+	Command []string       `json:"command,omitempty"` // command with arguments
+	Comment string         `json:"comment,omitempty"`
+}
+
+// Plan ordered changes to be made by installer in dry-run mode, and read-only checks performed
+type Plan struct {
+	Checks  []string      `json:"checks"`
+	Actions []*PlanAction `json:"actions"`
+}
+
+func NewPlan() *Plan {
+	return &Plan{
+		Checks:  []string{},
+		Actions: []*PlanAction{},
+	}
+}
+
+// AddCheck record a read-only check, nil plan is ignored
+func (this *Plan) AddCheck(check string) {
+	if this == nil {
+		return
+	}
+	this.Checks = append(this.Checks, check)
+}
+
+// Add record an action, nil plan is ignored
+func (this *Plan) Add(action *PlanAction) {
+	if this == nil {
+		return
+	}
+	this.Actions = append(this.Actions, action)
+}
+
+// JSON encode plan as indented json
+func (this *Plan) JSON() ([]byte, error) {
+	return json.MarshalIndent(this, "", "  ")
+}
+
+// String format plan as human readable text
+func (this *Plan) String() string {
+	var builder = &strings.Builder{}
+	builder.WriteString("checks:\n")
+	for _, check := range this.Checks {
+		builder.WriteString("  [ok] " + check + "\n")
+	}
+	builder.WriteString("\nactions:\n")
+	for index, action := range this.Actions {
+		builder.WriteString(strconv.Itoa(index+1) + ". " + action.summary() + "\n")
+		if len(action.Comment) > 0 {
+			builder.WriteString("   # " + action.Comment + "\n")
+		}
+		if len(action.Content) > 0 {
+			for _, line := range strings.Split(strings.TrimRight(action.Content, "\n"), "\n") {
+				builder.WriteString("   | " + line + "\n")
+			}
+		}
+	}
+	return builder.String()
+}
+
+// one line summary of the action
+func (this *PlanAction) summary() string {
+	switch this.Type {
+	case PlanActionDownload:
+		return "download '" + this.Name + "' to '" + this.Path + "'"
+	case PlanActionPackage:
+		return "install package '" + this.Name + "': " + strings.Join(this.Command, " ")
+	case PlanActionGroup:
+		return "create group '" + this.Name + "': " + strings.Join(this.Command, " ")
+	case PlanActionUser:
+		return "create user '" + this.Name + "': " + strings.Join(this.Command, " ")
+	case PlanActionDir:
+		return "create dir '" + this.Path + "' with mode " + this.Mode
+	case PlanActionExtract:
+		return "extract '" + this.Name + "' to '" + this.Path + "'"
+	case PlanActionRename:
+		return "rename '" + this.Path + "' to '" + this.Target + "'"
+	case PlanActionFile:
+		return "write file '" + this.Path + "' with mode " + this.Mode
+	case PlanActionCommand:
+		return "run: " + strings.Join(this.Command, " ")
+	case PlanActionRemove:
+		return "remove '" + this.Path + "'"
+	case PlanActionSymlink:
+		return "link '" + this.Path + "' to '" + this.Target + "'"
+	case PlanActionService:
+		return "enable service '" + this.Name + "': " + strings.Join(this.Command, " ")
+	case PlanActionSQL:
+		return "execute sql as '" + this.Name + "'"
+	}
+	return this.Type + " " + this.Path
+}
+
+// check whether the file will be downloaded
+func (this *Plan) hasDownload(path string) bool {
+	if this == nil {
+		return false
+	}
+	for _, action := range this.Actions {
+		if action.Type == PlanActionDownload && action.Path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers_test
+
+import (
+	"archive/tar"
+	"foolishmysql/internal/installers"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFoolishInstaller_DryRun(t *testing.T) {
+	var dir = t.TempDir()
+
+	// archive with a fake x86_64 executable
+	var archivePath = dir + "/mysql-8.0.36-linux-glibc2.17-x86_64-minimal.tar"
+	fp, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var elfHeader = "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00\x01\x00\x00\x00"
+	var writer = tar.NewWriter(fp)
+	err = writer.WriteHeader(&tar.Header{Name: "mysql-8.0.36-linux-glibc2.17-x86_64-minimal/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(elfHeader))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = writer.Write([]byte(elfHeader))
+	_ = writer.Close()
+	_ = fp.Close()
+
+	var targetDir = dir + "/mysql"
+	var installer = installers.NewFoolishInstaller().
+		WithPlatform("x86_64", "2.17").
+		WithInstance("dryrun").
+		WithPort(33061).
+		WithMyCnfOptions(map[string]string{"max_connections": "1024", "skip-name-resolve": "ON"}).
+		WithDatabases([]*installers.DatabaseConfig{{Name: "app", Charset: "utf8mb4"}}).
+		WithUsers([]*installers.UserConfig{{Name: "app", Host: "%"}}).
+		WithRootPassword("p'a\"ss#$`", 0).
+		WithHardening(true, true).
+		WithDryRun(true)
+	err = installer.InstallFromFile(archivePath, targetDir)
+	if err != nil {
+		if strings.Contains(err.Error(), "could not find") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+
+	// nothing should be changed
+	for _, file := range []string{targetDir, dir + "/.foolish-mysql-tmp", installer.ConfigFile()} {
+		_, err = os.Stat(file)
+		if err == nil {
+			t.Fatal("'" + file + "' should not be created in dry-run mode")
+		}
+	}
+
+	var plan = installer.Plan()
+	var foundConfig = false
+	var foundAccounts = false
+	var foundHardening = false
+	for _, action := range plan.Actions {
+		if action.Type == installers.PlanActionFile && action.Path == installer.ConfigFile() && strings.Contains(action.Content, "basedir=\""+targetDir+"\"") {
+			foundConfig = true
+			if !strings.Contains(action.Content, "\nmax_connections=1024\n") || strings.Contains(action.Content, "max_connections=256") || !strings.Contains(action.Content, "\nskip-name-resolve=ON") {
+				t.Fatal("options of my.cnf should be overridden:\n" + action.Content)
+			}
+		}
+		if action.Type == installers.PlanActionSQL && strings.Contains(action.Content, "CREATE DATABASE IF NOT EXISTS `app` DEFAULT CHARACTER SET utf8mb4;") {
+			foundAccounts = true
+		}
+		if action.Type == installers.PlanActionSQL && strings.Contains(action.Content, "DROP DATABASE `test`;") && strings.Contains(action.Content, "IDENTIFIED WITH auth_socket;") {
+			foundHardening = true
+		}
+		if strings.Contains(strings.Join(action.Command, " "), "--password=") || strings.Contains(action.Content, "p'a") {
+			t.Fatal("passwords should not be visible in command arguments or plan:", action.Command, action.Content)
+		}
+	}
+	if !foundConfig {
+		t.Fatal("config file should be planned")
+	}
+	if !foundAccounts {
+		t.Fatal("databases and users should be planned")
+	}
+	if !foundHardening {
+		t.Fatal("hardening should be planned")
+	}
+	t.Log(plan.String())
+}
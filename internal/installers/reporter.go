@@ -0,0 +1,89 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installers
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// Reporter receives messages and events of installer, inject it with WithReporter() option
+type Reporter interface {
+	// StepStarted an installation step is started
+	StepStarted(step StepName)
+
+	// StepFinished an installation step is completed, failed or skipped
+	StepFinished(event *StepEvent)
+
+	// Progress progress of a long task such as 'download' and 'extract', total is -1 if it is unknown
+	Progress(task string, bytes int64, total int64)
+
+	// Log informational message
+	Log(message string)
+
+	// Warning problem which does not stop installation
+	Warning(message string)
+}
+
+// ConsoleReporter default reporter, print messages with standard log package
+// nothing will be printed if QUIET environment variable is set
+type ConsoleReporter struct {
+	quiet bool
+
+	locker       sync.Mutex
+	lastProgress map[string]float64 // task => progress between 0 and 1
+}
+
+func NewConsoleReporter() *ConsoleReporter {
+	_, quiet := os.LookupEnv("QUIET")
+	return &ConsoleReporter{
+		quiet:        quiet,
+		lastProgress: map[string]float64{},
+	}
+}
+
+func (this *ConsoleReporter) StepStarted(step StepName) {
+	this.Log("running step '" + step + "' ...")
+}
+
+func (this *ConsoleReporter) StepFinished(event *StepEvent) {
+	if event.Status == StepStatusSkipped {
+		this.Log("skip step '" + event.Name + "'")
+	}
+}
+
+// Progress print progress every 10 percent
+func (this *ConsoleReporter) Progress(task string, bytes int64, total int64) {
+	if total <= 0 {
+		return
+	}
+	var progress = float64(bytes) / float64(total)
+
+	this.locker.Lock()
+	lastProgress, ok := this.lastProgress[task]
+	if ok && progress-lastProgress <= 0.1 && progress < 1 {
+		this.locker.Unlock()
+		return
+	}
+	if ok && lastProgress >= 1 && progress >= 1 {
+		this.locker.Unlock()
+		return
+	}
+	this.lastProgress[task] = progress
+	this.locker.Unlock()
+
+	this.Log(fmt.Sprintf("%s: %.2f%%", task, progress*100))
+}
+
+func (this *ConsoleReporter) Log(message string) {
+	if this.quiet {
+		return
+	}
+	log.Println(message)
+}
+
+func (this *ConsoleReporter) Warning(message string) {
+	this.Log("WARN: " + message)
+}
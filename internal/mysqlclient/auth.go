@@ -0,0 +1,93 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package mysqlclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// authentication plugins
+const (
+	authNativePassword      = "mysql_native_password"
+	authCachingSHA2Password = "caching_sha2_password"
+)
+
+// response of caching_sha2_password after scramble was sent
+const (
+	cachingSHA2RequestPublicKey byte = 0x02
+	cachingSHA2FastAuthSuccess  byte = 0x03
+	cachingSHA2PerformFullAuth  byte = 0x04
+)
+
+// scramble password with the nonce sent by server
+func scramblePassword(plugin string, password string, nonce []byte) ([]byte, error) {
+	switch plugin {
+	case authCachingSHA2Password:
+		return scrambleSHA256Password(password, nonce), nil
+	case authNativePassword:
+		return scrambleNativePassword(password, nonce), nil
+	}
+	return nil, errors.New("unsupported authentication plugin '" + plugin + "'")
+}
+
+// mysql_native_password: SHA1(password) XOR SHA1(nonce + SHA1(SHA1(password)))
+func scrambleNativePassword(password string, nonce []byte) []byte {
+	if len(password) == 0 {
+		return []byte{}
+	}
+	var stage1 = sha1.Sum([]byte(password))
+	var stage2 = sha1.Sum(stage1[:])
+	var hash = sha1.New()
+	hash.Write(nonce)
+	hash.Write(stage2[:])
+	var result = hash.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
+
+// caching_sha2_password: SHA256(password) XOR SHA256(SHA256(SHA256(password)) + nonce)
+func scrambleSHA256Password(password string, nonce []byte) []byte {
+	if len(password) == 0 {
+		return []byte{}
+	}
+	var message1 = sha256.Sum256([]byte(password))
+	var message2 = sha256.Sum256(message1[:])
+	var hash = sha256.New()
+	hash.Write(message2[:])
+	hash.Write(nonce)
+	var result = hash.Sum(nil)
+	for i := range result {
+		result[i] ^= message1[i]
+	}
+	return result
+}
+
+// encrypt password with public key of server, for full authentication without secure transport
+func encryptPassword(password string, nonce []byte, publicKeyPEM []byte) ([]byte, error) {
+	var block, _ = pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, errors.New("invalid public key of server")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("invalid public key of server: " + err.Error())
+	}
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("invalid public key of server: not a RSA key")
+	}
+
+	var plain = append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= nonce[i%len(nonce)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, plain, nil)
+}
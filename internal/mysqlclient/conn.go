@@ -0,0 +1,533 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package mysqlclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTimeout = 10 * time.Second
+
+	charsetUTF8MB4 byte = 45 // utf8mb4_general_ci
+)
+
+// Config connection options
+type Config struct {
+	Network  string // 'tcp' or 'unix', default is 'tcp'
+	Address  string // 'host:port', or path of unix socket
+	User     string
+	Password string
+	Database string
+
+	TLS          *tls.Config   // use TLS if not nil
+	TLSPreferred bool          // fall back to plain connection if server does not support TLS
+	Timeout      time.Duration // timeout of dialing and authentication, default is DefaultTimeout
+
+	// connect with an expired password, only statements changing password are allowed in the session
+	AllowExpiredPassword bool
+}
+
+// Result result of a query
+type Result struct {
+	AffectedRows uint64
+	LastInsertID uint64
+	Warnings     uint16
+	Columns      []string
+	Rows         [][]*string // NULL values are nil
+}
+
+// Conn a simple client connection of mysql protocol
+// it is not safe for concurrent use
+type Conn struct {
+	rawConn net.Conn // underlying socket
+	conn    net.Conn // socket, or TLS connection on it
+
+	sequence      byte
+	capabilities  uint32
+	status        uint16
+	serverVersion string
+	isTLS         bool
+	isUnix        bool
+}
+
+// Connect connect to server and authenticate
+func Connect(ctx context.Context, config *Config) (*Conn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var network = config.Network
+	if len(network) == 0 {
+		network = "tcp"
+	}
+	var timeout = config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	var dialer = &net.Dialer{Timeout: timeout}
+	rawConn, err := dialer.DialContext(ctx, network, config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn = &Conn{
+		rawConn: rawConn,
+		conn:    rawConn,
+		isUnix:  network == "unix",
+	}
+	_ = rawConn.SetDeadline(time.Now().Add(timeout))
+	var unwatch = conn.watch(ctx)
+	err = conn.handshake(ctx, config)
+	unwatch()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		_ = rawConn.Close()
+		return nil, err
+	}
+	_ = rawConn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// Probe check whether server accepts connections by reading its initial handshake, without authentication
+// version of server is returned
+func Probe(ctx context.Context, network string, address string, timeout time.Duration) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	var dialer = &net.Dialer{Timeout: timeout}
+	rawConn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = rawConn.Close()
+	}()
+
+	var conn = &Conn{
+		rawConn: rawConn,
+		conn:    rawConn,
+	}
+	_ = rawConn.SetDeadline(time.Now().Add(timeout))
+	var unwatch = conn.watch(ctx)
+	defer unwatch()
+	_, _, _, err = conn.readHandshake()
+	if err != nil {
+		return "", conn.contextErr(ctx, err)
+	}
+	return conn.serverVersion, nil
+}
+
+// Query execute a statement and read its result
+// only the first result is returned if the statement returns multiple results
+func (this *Conn) Query(ctx context.Context, sql string) (*Result, error) {
+	var unwatch = this.watch(ctx)
+	defer unwatch()
+
+	err := this.writeCommand(comQuery, []byte(sql))
+	if err != nil {
+		return nil, this.contextErr(ctx, err)
+	}
+
+	result, err := this.readResult()
+	if err != nil {
+		return nil, this.contextErr(ctx, err)
+	}
+
+	// discard other results, but errors in them are reported
+	for this.status&serverMoreResultsExists == serverMoreResultsExists {
+		_, err = this.readResult()
+		if err != nil {
+			return nil, this.contextErr(ctx, err)
+		}
+	}
+	return result, nil
+}
+
+// Exec execute a statement without reading rows
+func (this *Conn) Exec(ctx context.Context, sql string) error {
+	_, err := this.Query(ctx, sql)
+	return err
+}
+
+// Ping check whether the server is alive
+func (this *Conn) Ping(ctx context.Context) error {
+	var unwatch = this.watch(ctx)
+	defer unwatch()
+
+	err := this.writeCommand(comPing, nil)
+	if err != nil {
+		return this.contextErr(ctx, err)
+	}
+	data, err := this.readPacket()
+	if err != nil {
+		return this.contextErr(ctx, err)
+	}
+	switch data[0] {
+	case packetOK:
+		_, err = this.parseOK(data)
+		return err
+	case packetErr:
+		return this.parseError(data)
+	}
+	return ErrMalformedPacket
+}
+
+// Close send quit command and close connection
+func (this *Conn) Close() error {
+	_ = this.rawConn.SetDeadline(time.Now().Add(1 * time.Second))
+	_ = this.writeCommand(comQuit, nil)
+	return this.conn.Close()
+}
+
+// ServerVersion version sent by server in handshake, such as '8.0.32'
+func (this *Conn) ServerVersion() string {
+	return this.serverVersion
+}
+
+// IsTLS check whether the connection is encrypted with TLS
+func (this *Conn) IsTLS() bool {
+	return this.isTLS
+}
+
+// NoBackslashEscapes check whether 'NO_BACKSLASH_ESCAPES' sql mode is enabled in the session
+func (this *Conn) NoBackslashEscapes() bool {
+	return this.status&serverNoBackslashEscapes == serverNoBackslashEscapes
+}
+
+// QuoteString quote string literal in sql, escaped with backslashes
+// it does not work in sessions with 'NO_BACKSLASH_ESCAPES' sql mode
+func QuoteString(s string) string {
+	return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'", "\x00", "\\0", "\n", "\\n", "\r", "\\r", "\x1a", "\\Z").Replace(s) + "'"
+}
+
+// QuoteIdentifier quote database, table or column name
+func QuoteIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// interrupt reading and writing when context is done
+func (this *Conn) watch(ctx context.Context) (unwatch func()) {
+	if ctx == nil || ctx.Done() == nil {
+		return func() {}
+	}
+	var done = make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = this.rawConn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// return error of context instead of timeout error if context is done
+func (this *Conn) contextErr(ctx context.Context, err error) error {
+	if ctx != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// read initial handshake, and authenticate
+func (this *Conn) handshake(ctx context.Context, config *Config) error {
+	nonce, plugin, serverCapabilities, err := this.readHandshake()
+	if err != nil {
+		return err
+	}
+
+	this.capabilities = clientLongPassword | clientLongFlag | clientProtocol41 | clientTransactions | clientSecureConnection | clientMultiResults | clientPluginAuth | clientPluginAuthLenencClientData
+	if len(config.Database) > 0 {
+		this.capabilities |= clientConnectWithDB
+	}
+	if config.AllowExpiredPassword {
+		this.capabilities |= clientCanHandleExpiredPasswords
+	}
+	var useTLS = config.TLS != nil
+	if useTLS && serverCapabilities&clientSSL == 0 {
+		if !config.TLSPreferred {
+			return errors.New("server does not support TLS")
+		}
+		useTLS = false
+	}
+	if useTLS {
+		this.capabilities |= clientSSL
+	}
+	this.capabilities &= serverCapabilities
+
+	// upgrade to TLS before sending credentials
+	if useTLS {
+		err = this.writePacket(this.handshakeResponseHeader())
+		if err != nil {
+			return err
+		}
+		var tlsConn = tls.Client(this.rawConn, config.TLS)
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			return errors.New("TLS handshake failed: " + err.Error())
+		}
+		this.conn = tlsConn
+		this.isTLS = true
+	}
+
+	switch plugin {
+	case authNativePassword, authCachingSHA2Password:
+	default:
+		// let server switch to a supported one
+		plugin = authCachingSHA2Password
+	}
+	authData, err := scramblePassword(plugin, config.Password, nonce)
+	if err != nil {
+		return err
+	}
+
+	var response = this.handshakeResponseHeader()
+	response = append(response, config.User...)
+	response = append(response, 0)
+	if this.capabilities&clientPluginAuthLenencClientData == clientPluginAuthLenencClientData {
+		response = appendLenencInt(response, uint64(len(authData)))
+	} else {
+		response = append(response, byte(len(authData)))
+	}
+	response = append(response, authData...)
+	if this.capabilities&clientConnectWithDB == clientConnectWithDB {
+		response = append(response, config.Database...)
+		response = append(response, 0)
+	}
+	response = append(response, plugin...)
+	response = append(response, 0)
+	err = this.writePacket(response)
+	if err != nil {
+		return err
+	}
+
+	return this.authenticate(plugin, config.Password, nonce)
+}
+
+// read initial handshake packet sent by server
+func (this *Conn) readHandshake() (nonce []byte, plugin string, serverCapabilities uint32, err error) {
+	data, err := this.readPacket()
+	if err != nil {
+		return nil, "", 0, errors.New("read handshake failed: " + err.Error())
+	}
+	if data[0] == packetErr {
+		return nil, "", 0, this.parseError(data)
+	}
+
+	var reader = &packetReader{data: data}
+	var protocolVersion = reader.readByte()
+	if protocolVersion != 10 {
+		return nil, "", 0, errors.New("unsupported protocol version '" + strconv.Itoa(int(protocolVersion)) + "'")
+	}
+	this.serverVersion = reader.readNullString()
+	_ = reader.readUint32() // connection id
+	nonce = append([]byte{}, reader.next(8)...)
+	_ = reader.readByte() // filler
+	serverCapabilities = uint32(reader.readUint16())
+	plugin = authNativePassword
+	if reader.err == nil && len(reader.data) > 0 {
+		_ = reader.readByte() // charset
+		this.status = reader.readUint16()
+		serverCapabilities |= uint32(reader.readUint16()) << 16
+		var authDataLength = int(reader.readByte())
+		_ = reader.next(10) // reserved
+		if serverCapabilities&clientSecureConnection == clientSecureConnection {
+			var length = authDataLength - 8
+			if length < 13 {
+				length = 13
+			}
+			var part2 = reader.next(length)
+			if len(part2) > 12 {
+				part2 = part2[:12]
+			}
+			nonce = append(nonce, part2...)
+		}
+		if serverCapabilities&clientPluginAuth == clientPluginAuth {
+			plugin = reader.readNullString()
+		}
+	}
+	if reader.err != nil {
+		return nil, "", 0, errors.New("read handshake failed: " + reader.err.Error())
+	}
+	if serverCapabilities&clientProtocol41 == 0 {
+		return nil, "", 0, errors.New("server '" + this.serverVersion + "' is too old")
+	}
+	return nonce, plugin, serverCapabilities, nil
+}
+
+// capabilities, max packet size, charset and reserved bytes, also used as SSL request
+func (this *Conn) handshakeResponseHeader() []byte {
+	var header = make([]byte, 32)
+	binary.LittleEndian.PutUint32(header[0:4], this.capabilities)
+	binary.LittleEndian.PutUint32(header[4:8], maxPacketSize)
+	header[8] = charsetUTF8MB4
+	return header
+}
+
+// read authentication results until OK or error packet
+func (this *Conn) authenticate(plugin string, password string, nonce []byte) error {
+	var publicKeyRequested = false
+	for {
+		data, err := this.readPacket()
+		if err != nil {
+			return errors.New("read authentication result failed: " + err.Error())
+		}
+		switch data[0] {
+		case packetOK:
+			_, err = this.parseOK(data)
+			return err
+		case packetErr:
+			return this.parseError(data)
+		case packetEOF:
+			// switch authentication plugin
+			var reader = &packetReader{data: data[1:]}
+			plugin = reader.readNullString()
+			nonce = append([]byte{}, reader.data...)
+			if len(nonce) > 0 && nonce[len(nonce)-1] == 0 {
+				nonce = nonce[:len(nonce)-1]
+			}
+			authData, err := scramblePassword(plugin, password, nonce)
+			if err != nil {
+				return err
+			}
+			err = this.writePacket(authData)
+			if err != nil {
+				return err
+			}
+			publicKeyRequested = false
+		case packetAuthMore:
+			if plugin != authCachingSHA2Password || len(data) < 2 {
+				return ErrMalformedPacket
+			}
+			if publicKeyRequested {
+				encrypted, err := encryptPassword(password, nonce, data[1:])
+				if err != nil {
+					return err
+				}
+				err = this.writePacket(encrypted)
+				if err != nil {
+					return err
+				}
+				publicKeyRequested = false
+				continue
+			}
+			switch data[1] {
+			case cachingSHA2FastAuthSuccess:
+				// OK packet follows
+			case cachingSHA2PerformFullAuth:
+				// password can be sent in clear text only on secure transport
+				if this.isTLS || this.isUnix {
+					err = this.writePacket(append([]byte(password), 0))
+				} else {
+					err = this.writePacket([]byte{cachingSHA2RequestPublicKey})
+					publicKeyRequested = true
+				}
+				if err != nil {
+					return err
+				}
+			default:
+				return ErrMalformedPacket
+			}
+		default:
+			return ErrMalformedPacket
+		}
+	}
+}
+
+// read OK, error or result set packets of a query
+func (this *Conn) readResult() (*Result, error) {
+	data, err := this.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch data[0] {
+	case packetOK:
+		return this.parseOK(data)
+	case packetErr:
+		return nil, this.parseError(data)
+	case packetLocalFile:
+		// refuse to send local files, server responds with an error
+		err = this.writePacket([]byte{})
+		if err != nil {
+			return nil, err
+		}
+		_, err = this.readResult()
+		if err == nil {
+			err = errors.New("LOAD DATA LOCAL is not supported")
+		}
+		return nil, err
+	}
+
+	var reader = &packetReader{data: data}
+	var columnCount = reader.readLenencInt()
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	var result = &Result{}
+	for i := uint64(0); i < columnCount; i++ {
+		data, err = this.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		var columnReader = &packetReader{data: data}
+		for j := 0; j < 4; j++ {
+			_ = columnReader.readLenencString() // catalog, schema, table and original table
+		}
+		var name = columnReader.readLenencString()
+		if columnReader.err != nil || name == nil {
+			return nil, ErrMalformedPacket
+		}
+		result.Columns = append(result.Columns, *name)
+	}
+
+	// EOF after column definitions
+	data, err = this.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if !isEOFPacket(data) {
+		return nil, ErrMalformedPacket
+	}
+
+	for {
+		data, err = this.readPacket()
+		if err != nil {
+			return nil, err
+		}
+		if data[0] == packetErr {
+			return nil, this.parseError(data)
+		}
+		if isEOFPacket(data) {
+			var eofReader = &packetReader{data: data[1:]}
+			result.Warnings = eofReader.readUint16()
+			this.status = eofReader.readUint16()
+			return result, nil
+		}
+
+		var rowReader = &packetReader{data: data}
+		var row = make([]*string, 0, columnCount)
+		for i := uint64(0); i < columnCount; i++ {
+			row = append(row, rowReader.readLenencString())
+		}
+		if rowReader.err != nil {
+			return nil, rowReader.err
+		}
+		result.Rows = append(result.Rows, row)
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package mysqlclient_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"foolishmysql/internal/mysqlclient"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConnect_ExpiredPassword(t *testing.T) {
+	var socket = startFakeServer(t, "p'a\"ss", true)
+
+	// clients can not login without supporting expired passwords
+	_, err := mysqlclient.Connect(context.Background(), &mysqlclient.Config{
+		Network:  "unix",
+		Address:  socket,
+		User:     "root",
+		Password: "p'a\"ss",
+	})
+	if !mysqlclient.IsErrorCode(err, mysqlclient.ErrCodeMustChangePasswordLogin) {
+		t.Fatal("expect error 1862, but got:", err)
+	}
+
+	conn, err := mysqlclient.Connect(context.Background(), &mysqlclient.Config{
+		Network:              "unix",
+		Address:              socket,
+		User:                 "root",
+		Password:             "p'a\"ss",
+		AllowExpiredPassword: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_, err = conn.Query(context.Background(), "SELECT 1")
+	if !mysqlclient.IsErrorCode(err, mysqlclient.ErrCodeMustChangePassword) {
+		t.Fatal("expect error 1820, but got:", err)
+	}
+	if err.Error() != "ERROR 1820 (HY000): You must reset your password using ALTER USER statement before executing this statement." {
+		t.Fatal("unexpected error message:", err)
+	}
+
+	err = conn.Exec(context.Background(), "ALTER USER 'root'@'localhost' IDENTIFIED BY "+mysqlclient.QuoteString("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conn.Query(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.Columns, ",") != "name,value" || len(result.Rows) != 2 {
+		t.Fatal("unexpected result:", result.Columns, result.Rows)
+	}
+	if *result.Rows[0][0] != "a" || *result.Rows[0][1] != "1" || *result.Rows[1][0] != "b" || result.Rows[1][1] != nil {
+		t.Fatal("unexpected rows")
+	}
+
+	err = conn.Ping(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnect_AccessDenied(t *testing.T) {
+	var socket = startFakeServer(t, "123456", false)
+	_, err := mysqlclient.Connect(context.Background(), &mysqlclient.Config{
+		Network:  "unix",
+		Address:  socket,
+		User:     "root",
+		Password: "654321",
+	})
+	if !mysqlclient.IsErrorCode(err, mysqlclient.ErrCodeAccessDenied) {
+		t.Fatal("expect error 1045, but got:", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	var socket = startFakeServer(t, "123456", false)
+	version, err := mysqlclient.Probe(context.Background(), "unix", socket, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "8.0.32" {
+		t.Fatal("expect '8.0.32', but got '" + version + "'")
+	}
+
+	// not a mysql server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.0\r\n"))
+			_ = conn.Close()
+		}
+	}()
+	_, err = mysqlclient.Probe(context.Background(), "tcp", listener.Addr().String(), 0)
+	if err == nil {
+		t.Fatal("probe should fail on other servers")
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	for s, expected := range map[string]string{
+		"abc":      "'abc'",
+		"a'b":      `'a\'b'`,
+		`a\'b`:     `'a\\\'b'`,
+		"a\x00\nb": `'a\0\nb'`,
+	} {
+		if mysqlclient.QuoteString(s) != expected {
+			t.Fatal("quote '"+s+"' failed:", mysqlclient.QuoteString(s))
+		}
+	}
+}
+
+// start a fake server on unix socket, which authenticates 'root' with caching_sha2_password
+func startFakeServer(t *testing.T, password string, expired bool) string {
+	var socket = filepath.Join(t.TempDir(), "mysql.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+				(&fakeServerConn{conn: conn, password: password, expired: expired}).serve()
+			}()
+		}
+	}()
+	return socket
+}
+
+type fakeServerConn struct {
+	conn     net.Conn
+	sequence byte
+	password string
+	expired  bool
+}
+
+func (this *fakeServerConn) serve() {
+	var nonce = []byte("0123456789abcdefghij")
+	var handshake = []byte{10}
+	handshake = append(handshake, "8.0.32\x00"...)
+	handshake = append(handshake, 1, 0, 0, 0)
+	handshake = append(handshake, nonce[:8]...)
+	handshake = append(handshake, 0, 0xff, 0xff, 45, 2, 0, 0xff, 0xff, 21)
+	handshake = append(handshake, make([]byte, 10)...)
+	handshake = append(handshake, nonce[8:]...)
+	handshake = append(handshake, 0)
+	handshake = append(handshake, "caching_sha2_password\x00"...)
+	this.write(handshake)
+
+	response, ok := this.read()
+	if !ok || len(response) < 33 {
+		return
+	}
+	var capabilities = binary.LittleEndian.Uint32(response)
+	var userEnd = 32 + bytes.IndexByte(response[32:], 0)
+	var authLength = int(response[userEnd+1])
+	var authData = response[userEnd+2 : userEnd+2+authLength]
+	if string(response[32:userEnd]) != "root" || !bytes.Equal(authData, this.scramble(nonce)) {
+		this.writeError(1045, "28000", "Access denied for user 'root'@'localhost' (using password: YES)")
+		return
+	}
+
+	// require full authentication, password is sent in clear text on unix socket
+	this.write([]byte{0x01, 0x04})
+	clearPassword, ok := this.read()
+	if !ok || string(clearPassword) != this.password+"\x00" {
+		this.writeError(1045, "28000", "Access denied for user 'root'@'localhost' (using password: YES)")
+		return
+	}
+	if this.expired && capabilities&0x00400000 == 0 {
+		this.writeError(1862, "HY000", "Your password has expired. To log in you must change it using a client that supports expired passwords.")
+		return
+	}
+	this.writeOK()
+
+	for {
+		this.sequence = 0
+		command, ok := this.read()
+		if !ok || len(command) == 0 {
+			return
+		}
+		switch command[0] {
+		case 0x01:
+			return
+		case 0x0e:
+			this.writeOK()
+		case 0x03:
+			var query = string(command[1:])
+			if strings.HasPrefix(query, "ALTER USER") {
+				this.expired = false
+				this.writeOK()
+			} else if this.expired {
+				this.writeError(1820, "HY000", "You must reset your password using ALTER USER statement before executing this statement.")
+			} else {
+				this.writeRows()
+			}
+		}
+	}
+}
+
+func (this *fakeServerConn) scramble(nonce []byte) []byte {
+	var message1 = sha256.Sum256([]byte(this.password))
+	var message2 = sha256.Sum256(message1[:])
+	var message3 = sha256.Sum256(append(message2[:], nonce...))
+	for i := range message3 {
+		message3[i] ^= message1[i]
+	}
+	return message3[:]
+}
+
+func (this *fakeServerConn) read() ([]byte, bool) {
+	var header = make([]byte, 4)
+	_, err := io.ReadFull(this.conn, header)
+	if err != nil {
+		return nil, false
+	}
+	this.sequence = header[3] + 1
+	var data = make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	_, err = io.ReadFull(this.conn, data)
+	return data, err == nil
+}
+
+func (this *fakeServerConn) write(data []byte) {
+	var length = len(data)
+	_, _ = this.conn.Write(append([]byte{byte(length), byte(length >> 8), byte(length >> 16), this.sequence}, data...))
+	this.sequence++
+}
+
+func (this *fakeServerConn) writeOK() {
+	this.write([]byte{0x00, 0, 0, 2, 0, 0, 0})
+}
+
+func (this *fakeServerConn) writeError(code uint16, state string, message string) {
+	this.write(append([]byte{0xff, byte(code), byte(code >> 8), '#'}, state+message...))
+}
+
+// result set with columns 'name' and 'value', and rows ('a', '1'), ('b', NULL)
+func (this *fakeServerConn) writeRows() {
+	this.write([]byte{2})
+	for _, name := range []string{"name", "value"} {
+		var column = []byte{3, 'd', 'e', 'f', 0, 0, 0, byte(len(name))}
+		column = append(column, name...)
+		column = append(column, 0, 0x0c, 45, 0, 255, 0, 0, 0, 0xfd, 0, 0, 0, 0, 0)
+		this.write(column)
+	}
+	this.write([]byte{0xfe, 0, 0, 2, 0})
+	this.write([]byte{1, 'a', 1, '1'})
+	this.write([]byte{1, 'b', 0xfb})
+	this.write([]byte{0xfe, 0, 0, 2, 0})
+}
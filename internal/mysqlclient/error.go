@@ -0,0 +1,39 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package mysqlclient
+
+import (
+	"errors"
+	"strconv"
+)
+
+// error codes returned by server
+const (
+	ErrCodeAccessDenied            uint16 = 1045
+	ErrCodeBadDatabase             uint16 = 1049
+	ErrCodeMustChangePassword      uint16 = 1820 // statement is not allowed before changing expired password
+	ErrCodeMustChangePasswordLogin uint16 = 1862 // client does not support expired passwords
+)
+
+var ErrMalformedPacket = errors.New("malformed packet")
+
+// Error error packet returned by server
+type Error struct {
+	Code     uint16
+	SQLState string
+	Message  string
+}
+
+// Error format error like mysql client, such as 'ERROR 1045 (28000): Access denied for user ...'
+func (this *Error) Error() string {
+	if len(this.SQLState) > 0 {
+		return "ERROR " + strconv.Itoa(int(this.Code)) + " (" + this.SQLState + "): " + this.Message
+	}
+	return "ERROR " + strconv.Itoa(int(this.Code)) + ": " + this.Message
+}
+
+// IsErrorCode check whether the error is returned by server with the code
+func IsErrorCode(err error, code uint16) bool {
+	var mysqlErr *Error
+	return errors.As(err, &mysqlErr) && mysqlErr.Code == code
+}
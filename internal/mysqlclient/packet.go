@@ -0,0 +1,261 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package mysqlclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const maxPacketSize = 1<<24 - 1
+
+// first byte of packets
+const (
+	packetOK        byte = 0x00
+	packetAuthMore  byte = 0x01
+	packetLocalFile byte = 0xfb
+	packetEOF       byte = 0xfe // also auth switch request in authentication
+	packetErr       byte = 0xff
+)
+
+// commands
+const (
+	comQuit  byte = 0x01
+	comQuery byte = 0x03
+	comPing  byte = 0x0e
+)
+
+// capability flags
+const (
+	clientLongPassword               uint32 = 0x00000001
+	clientLongFlag                   uint32 = 0x00000004
+	clientConnectWithDB              uint32 = 0x00000008
+	clientProtocol41                 uint32 = 0x00000200
+	clientSSL                        uint32 = 0x00000800
+	clientTransactions               uint32 = 0x00002000
+	clientSecureConnection           uint32 = 0x00008000
+	clientMultiResults               uint32 = 0x00020000
+	clientPluginAuth                 uint32 = 0x00080000
+	clientPluginAuthLenencClientData uint32 = 0x00200000
+	clientCanHandleExpiredPasswords  uint32 = 0x00400000
+)
+
+// status flags
+const (
+	serverMoreResultsExists  uint16 = 0x0008
+	serverNoBackslashEscapes uint16 = 0x0200
+)
+
+// read one packet, packets larger than 16MB are joined
+func (this *Conn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		var header = make([]byte, 4)
+		_, err := io.ReadFull(this.conn, header)
+		if err != nil {
+			return nil, err
+		}
+		var length = int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		if header[3] != this.sequence {
+			return nil, errors.New("invalid packet sequence " + strconv.Itoa(int(header[3])) + ", expected " + strconv.Itoa(int(this.sequence)))
+		}
+		this.sequence++
+
+		var data = make([]byte, length)
+		_, err = io.ReadFull(this.conn, data)
+		if err != nil {
+			return nil, err
+		}
+		payload = append(payload, data...)
+		if length < maxPacketSize {
+			if len(payload) == 0 {
+				return nil, ErrMalformedPacket
+			}
+			return payload, nil
+		}
+	}
+}
+
+// write payload as one or more packets
+func (this *Conn) writePacket(payload []byte) error {
+	for {
+		var length = len(payload)
+		if length > maxPacketSize {
+			length = maxPacketSize
+		}
+		var packet = make([]byte, 4, 4+length)
+		packet[0] = byte(length)
+		packet[1] = byte(length >> 8)
+		packet[2] = byte(length >> 16)
+		packet[3] = this.sequence
+		this.sequence++
+		packet = append(packet, payload[:length]...)
+		_, err := this.conn.Write(packet)
+		if err != nil {
+			return err
+		}
+		payload = payload[length:]
+
+		// packet with max size is followed by another one, even if it is empty
+		if length < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+// write command packet with a new sequence
+func (this *Conn) writeCommand(command byte, arg []byte) error {
+	this.sequence = 0
+	return this.writePacket(append([]byte{command}, arg...))
+}
+
+// parse error packet
+func (this *Conn) parseError(data []byte) error {
+	if len(data) < 3 {
+		return ErrMalformedPacket
+	}
+	var mysqlErr = &Error{Code: binary.LittleEndian.Uint16(data[1:3])}
+	var message = data[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		mysqlErr.SQLState = string(message[1:6])
+		message = message[6:]
+	}
+	mysqlErr.Message = string(message)
+	return mysqlErr
+}
+
+// parse ok packet
+func (this *Conn) parseOK(data []byte) (*Result, error) {
+	var reader = &packetReader{data: data[1:]}
+	var result = &Result{}
+	result.AffectedRows = reader.readLenencInt()
+	result.LastInsertID = reader.readLenencInt()
+	this.status = reader.readUint16()
+	result.Warnings = reader.readUint16()
+	if reader.err != nil {
+		return nil, reader.err
+	}
+	return result, nil
+}
+
+// check whether the packet is an EOF packet, rows may also start with 0xfe if they are large enough
+func isEOFPacket(data []byte) bool {
+	return len(data) > 0 && data[0] == packetEOF && len(data) < 9
+}
+
+// packetReader read fields from packet payload
+// the first error is kept, and zero values are returned after it
+type packetReader struct {
+	data []byte
+	err  error
+}
+
+func (this *packetReader) next(n int) []byte {
+	if this.err != nil {
+		return nil
+	}
+	if n > len(this.data) {
+		this.err = ErrMalformedPacket
+		return nil
+	}
+	var result = this.data[:n]
+	this.data = this.data[n:]
+	return result
+}
+
+func (this *packetReader) readByte() byte {
+	var b = this.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (this *packetReader) readUint16() uint16 {
+	var b = this.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (this *packetReader) readUint32() uint32 {
+	var b = this.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+// read null terminated string, or the rest of data if there is no terminator
+func (this *packetReader) readNullString() string {
+	if this.err != nil {
+		return ""
+	}
+	var index = bytes.IndexByte(this.data, 0)
+	if index < 0 {
+		var s = string(this.data)
+		this.data = nil
+		return s
+	}
+	var s = string(this.data[:index])
+	this.data = this.data[index+1:]
+	return s
+}
+
+// read length encoded integer
+func (this *packetReader) readLenencInt() uint64 {
+	var first = this.readByte()
+	switch first {
+	case 0xfc:
+		var b = this.next(2)
+		if b == nil {
+			return 0
+		}
+		return uint64(binary.LittleEndian.Uint16(b))
+	case 0xfd:
+		var b = this.next(3)
+		if b == nil {
+			return 0
+		}
+		return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16
+	case 0xfe:
+		var b = this.next(8)
+		if b == nil {
+			return 0
+		}
+		return binary.LittleEndian.Uint64(b)
+	}
+	return uint64(first)
+}
+
+// read length encoded string, NULL (0xfb) is returned as nil
+func (this *packetReader) readLenencString() *string {
+	if this.err == nil && len(this.data) > 0 && this.data[0] == 0xfb {
+		this.data = this.data[1:]
+		return nil
+	}
+	var length = this.readLenencInt()
+	if length > uint64(len(this.data)) {
+		this.err = ErrMalformedPacket
+		return nil
+	}
+	var s = string(this.next(int(length)))
+	return &s
+}
+
+// append length encoded integer
+func appendLenencInt(data []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(data, byte(n))
+	case n < 1<<16:
+		return append(data, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(data, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return append(data, 0xfe, byte(n), byte(n>>8), byte(n>>16), byte(n>>24), byte(n>>32), byte(n>>40), byte(n>>48), byte(n>>56))
+}
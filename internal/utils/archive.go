@@ -0,0 +1,386 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ulikunitz/xz"
+)
+
+type ArchiveFormat = string
+
+const (
+	ArchiveFormatTar   ArchiveFormat = "tar"
+	ArchiveFormatTarGz ArchiveFormat = "tar.gz"
+	ArchiveFormatTarXz ArchiveFormat = "tar.xz"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	tarMagic  = []byte("ustar")
+)
+
+const tarMagicOffset = 257
+
+// DetectArchiveFormat detect archive format with magic bytes instead of file extension
+func DetectArchiveFormat(file string) (ArchiveFormat, error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	var header = make([]byte, tarMagicOffset+len(tarMagic))
+	n, err := io.ReadFull(fp, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", errors.New("read file header failed: " + err.Error())
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return ArchiveFormatTarGz, nil
+	case bytes.HasPrefix(header, xzMagic):
+		return ArchiveFormatTarXz, nil
+	case len(header) == tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:], tarMagic):
+		return ArchiveFormatTar, nil
+	}
+	return "", errors.New("unsupported archive format, only tar, tar.gz and tar.xz are supported")
+}
+
+// DetectArchiveArch detect architecture of the archive from the first ELF executable in its 'bin/' dir
+// empty string will be returned if there is no executable in archive
+func DetectArchiveArch(file string) (string, error) {
+	format, err := DetectArchiveFormat(file)
+	if err != nil {
+		return "", err
+	}
+
+	fp, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	reader, err := decompressReader(format, fp)
+	if err != nil {
+		return "", err
+	}
+
+	var tarReader = tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", err
+		}
+		if header.Typeflag != tar.TypeReg || !strings.Contains("/"+header.Name, "/bin/") {
+			continue
+		}
+
+		var elfHeader = make([]byte, 20)
+		_, err = io.ReadFull(tarReader, elfHeader)
+		if err != nil {
+			continue
+		}
+		var arch = elfArch(elfHeader)
+		if len(arch) > 0 {
+			return arch, nil
+		}
+	}
+}
+
+// ArchiveExtractor extract tar, .tar.gz and .tar.xz archive without external 'tar' command
+type ArchiveExtractor struct {
+	file string
+	ctx  context.Context
+
+	onProgress func(name string, written int64, total int64)
+}
+
+func NewArchiveExtractor(file string) *ArchiveExtractor {
+	return &ArchiveExtractor{
+		file: file,
+		ctx:  context.Background(),
+	}
+}
+
+// OnProgress set callback called after each entry was extracted
+// written and total are compressed bytes read from archive file and size of archive file
+func (this *ArchiveExtractor) OnProgress(f func(name string, written int64, total int64)) *ArchiveExtractor {
+	this.onProgress = f
+	return this
+}
+
+// WithContext set context to stop extracting, extracted files are kept
+func (this *ArchiveExtractor) WithContext(ctx context.Context) *ArchiveExtractor {
+	this.ctx = ctx
+	return this
+}
+
+// ExtractTo extract all entries into target dir
+func (this *ArchiveExtractor) ExtractTo(targetDir string) error {
+	err := os.MkdirAll(targetDir, 0700)
+	if err != nil {
+		return err
+	}
+	targetDir, err = filepath.Abs(targetDir)
+	if err != nil {
+		return err
+	}
+	targetDir, err = filepath.EvalSymlinks(targetDir)
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.Open(this.file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	stat, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+
+	format, err := DetectArchiveFormat(this.file)
+	if err != nil {
+		return err
+	}
+
+	var progressWriter = NewProgressWriter(io.Discard, stat.Size())
+	reader, err := decompressReader(format, io.TeeReader(fp, progressWriter))
+	if err != nil {
+		return err
+	}
+
+	var tarReader = tar.NewReader(reader)
+
+	// directories modes and times should be applied after all files were written
+	type dirMeta struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	var dirs = []*dirMeta{}
+
+	for {
+		if this.ctx.Err() != nil {
+			return this.ctx.Err()
+		}
+
+		header, err := tarReader.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return errors.New("read archive failed: " + err.Error())
+		}
+
+		target, err := this.securePath(targetDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		var mode = header.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0700)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, &dirMeta{
+				path:    target,
+				mode:    mode,
+				modTime: header.ModTime,
+			})
+		case tar.TypeReg:
+			err = this.writeFile(target, tarReader, mode)
+			if err != nil {
+				return err
+			}
+			_ = os.Chtimes(target, header.ModTime, header.ModTime)
+		case tar.TypeSymlink:
+			// link target should be inside target dir too
+			var linkTarget = header.Linkname
+			if !filepath.IsAbs(linkTarget) {
+				linkTarget = filepath.Join(filepath.Dir(target), linkTarget)
+			}
+			if !this.isInDir(targetDir, linkTarget) {
+				return errors.New("invalid symbolic link '" + header.Name + "' -> '" + header.Linkname + "' in archive: link target is outside of target dir")
+			}
+
+			err = this.prepareParent(target)
+			if err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			err = os.Symlink(header.Linkname, target)
+			if err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			linkTarget, err := this.securePath(targetDir, header.Linkname)
+			if err != nil {
+				return err
+			}
+			err = this.prepareParent(target)
+			if err != nil {
+				return err
+			}
+			_ = os.Remove(target)
+			err = os.Link(linkTarget, target)
+			if err != nil {
+				return err
+			}
+		default:
+			// ignore other types such as char devices, fifo ...
+			continue
+		}
+
+		if this.onProgress != nil {
+			this.onProgress(header.Name, progressWriter.Written(), progressWriter.Total())
+		}
+	}
+
+	// apply directory modes from the deepest one
+	for i := len(dirs) - 1; i >= 0; i-- {
+		var dir = dirs[i]
+		_ = os.Chmod(dir.path, dir.mode)
+		_ = os.Chtimes(dir.path, dir.modTime, dir.modTime)
+	}
+
+	return nil
+}
+
+func (this *ArchiveExtractor) writeFile(target string, reader io.Reader, mode os.FileMode) error {
+	err := this.prepareParent(target)
+	if err != nil {
+		return err
+	}
+
+	// remove old file or link, avoid writing through a symbolic link
+	_ = os.Remove(target)
+
+	fp, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fp, reader)
+	if err != nil {
+		_ = fp.Close()
+		return errors.New("write file '" + target + "' failed: " + err.Error())
+	}
+	err = fp.Close()
+	if err != nil {
+		return err
+	}
+
+	// chmod after writing, so setuid/setgid bits will not be cleared
+	return os.Chmod(target, mode)
+}
+
+// create parent directory
+func (this *ArchiveExtractor) prepareParent(target string) error {
+	var parentDir = filepath.Dir(target)
+	err := os.MkdirAll(parentDir, 0700)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// join name with target dir, refuse absolute path and path traversal
+func (this *ArchiveExtractor) securePath(targetDir string, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", errors.New("invalid entry '" + name + "' in archive: absolute path is not allowed")
+	}
+	var target = filepath.Join(targetDir, name)
+	if !this.isInDir(targetDir, target) {
+		return "", errors.New("invalid entry '" + name + "' in archive: path is outside of target dir")
+	}
+
+	// parent directories should not be symbolic links pointing to outside
+	for parentDir := filepath.Dir(target); this.isInDir(targetDir, parentDir) && parentDir != targetDir; parentDir = filepath.Dir(parentDir) {
+		realParent, err := filepath.EvalSymlinks(parentDir)
+		if err != nil {
+			// not created yet, check upper level
+			continue
+		}
+		if !this.isInDir(targetDir, realParent) {
+			return "", errors.New("invalid entry '" + name + "' in archive: parent directory is a link pointing to outside of target dir")
+		}
+		break
+	}
+
+	return target, nil
+}
+
+func (this *ArchiveExtractor) isInDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// wrap reader with decompressor of the format
+func decompressReader(format ArchiveFormat, reader io.Reader) (io.Reader, error) {
+	switch format {
+	case ArchiveFormatTarGz:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, errors.New("invalid gzip file: " + err.Error())
+		}
+		return gzipReader, nil
+	case ArchiveFormatTarXz:
+		xzReader, err := xz.NewReader(reader)
+		if err != nil {
+			return nil, errors.New("invalid xz file: " + err.Error())
+		}
+		return xzReader, nil
+	}
+	return reader, nil
+}
+
+// parse machine of ELF header
+func elfArch(header []byte) string {
+	if len(header) < 20 || !bytes.Equal(header[:4], []byte("\x7fELF")) {
+		return ""
+	}
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	if header[5] == 2 {
+		byteOrder = binary.BigEndian
+	}
+	switch byteOrder.Uint16(header[18:20]) {
+	case 0x3e:
+		return ArchX86_64
+	case 0xb7:
+		return ArchAarch64
+	case 0x03:
+		return "i686"
+	}
+	return "unknown"
+}
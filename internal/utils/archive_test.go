@@ -0,0 +1,218 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"foolishmysql/internal/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+type testArchiveEntry struct {
+	header *tar.Header
+	body   string
+}
+
+func writeTestArchive(t *testing.T, path string, format utils.ArchiveFormat, entries []*testArchiveEntry) {
+	fp, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	var writer io.WriteCloser
+	switch format {
+	case utils.ArchiveFormatTarXz:
+		writer, err = xz.NewWriter(fp)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case utils.ArchiveFormatTarGz:
+		writer = gzip.NewWriter(fp)
+	default:
+		writer = fp
+	}
+	var tarWriter = tar.NewWriter(writer)
+	for _, entry := range entries {
+		entry.header.Size = int64(len(entry.body))
+		err = tarWriter.WriteHeader(entry.header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entry.body) > 0 {
+			_, err = tarWriter.Write([]byte(entry.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	var tmpDir = t.TempDir()
+	for _, format := range []utils.ArchiveFormat{utils.ArchiveFormatTar, utils.ArchiveFormatTarGz, utils.ArchiveFormatTarXz} {
+		// use misleading extension, format should be detected by content
+		var archiveFile = tmpDir + "/mysql-test.xz"
+		writeTestArchive(t, archiveFile, format, []*testArchiveEntry{
+			{header: &tar.Header{Name: "mysql-test/README", Typeflag: tar.TypeReg, Mode: 0644}, body: "readme"},
+		})
+		detectedFormat, err := utils.DetectArchiveFormat(archiveFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detectedFormat != format {
+			t.Fatal("expect '" + format + "', but got '" + detectedFormat + "'")
+		}
+
+		err = utils.NewArchiveExtractor(archiveFile).ExtractTo(tmpDir + "/target-" + format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(tmpDir + "/target-" + format + "/mysql-test/README")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "readme" {
+			t.Fatal("invalid content:", string(data))
+		}
+	}
+
+	// not an archive
+	var textFile = tmpDir + "/text.tar.xz"
+	err := os.WriteFile(textFile, []byte("hello"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = utils.DetectArchiveFormat(textFile)
+	if err == nil {
+		t.Fatal("text file should not be detected as archive")
+	}
+}
+
+func TestArchiveExtractor_ExtractTo(t *testing.T) {
+	var tmpDir = t.TempDir()
+	var archiveFile = tmpDir + "/mysql-test.tar.xz"
+	writeTestArchive(t, archiveFile, utils.ArchiveFormatTarXz, []*testArchiveEntry{
+		{header: &tar.Header{Name: "mysql-test/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: &tar.Header{Name: "mysql-test/bin/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{header: &tar.Header{Name: "mysql-test/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755}, body: "#!/bin/sh\n"},
+		{header: &tar.Header{Name: "mysql-test/lib/libtest.so.1.0", Typeflag: tar.TypeReg, Mode: 0644}, body: "lib"},
+		{header: &tar.Header{Name: "mysql-test/lib/libtest.so", Typeflag: tar.TypeSymlink, Linkname: "libtest.so.1.0"}},
+		{header: &tar.Header{Name: "mysql-test/bin/mysqld-debug", Typeflag: tar.TypeLink, Linkname: "mysql-test/bin/mysqld"}},
+	})
+
+	var names = []string{}
+	err := utils.NewArchiveExtractor(archiveFile).
+		OnProgress(func(name string, written int64, total int64) {
+			if written > total {
+				t.Fatal("written bytes should not exceed archive size")
+			}
+			names = append(names, name)
+		}).
+		ExtractTo(tmpDir + "/target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 6 {
+		t.Fatal("expect 6 entries, but got", len(names))
+	}
+
+	stat, err := os.Stat(tmpDir + "/target/mysql-test/bin/mysqld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm() != 0755 {
+		t.Fatal("invalid mode:", stat.Mode().Perm())
+	}
+
+	link, err := os.Readlink(tmpDir + "/target/mysql-test/lib/libtest.so")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "libtest.so.1.0" {
+		t.Fatal("invalid link:", link)
+	}
+
+	hardStat, err := os.Stat(tmpDir + "/target/mysql-test/bin/mysqld-debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(stat, hardStat) {
+		t.Fatal("hard link should point to the same file")
+	}
+}
+
+func TestArchiveExtractor_ExtractTo_Traversal(t *testing.T) {
+	for _, entry := range []*testArchiveEntry{
+		{header: &tar.Header{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0644}, body: "evil"},
+		{header: &tar.Header{Name: "/etc/evil", Typeflag: tar.TypeReg, Mode: 0644}, body: "evil"},
+		{header: &tar.Header{Name: "mysql-test/evil", Typeflag: tar.TypeSymlink, Linkname: "../../evil"}},
+		{header: &tar.Header{Name: "mysql-test/evil", Typeflag: tar.TypeLink, Linkname: "../evil"}},
+	} {
+		var tmpDir = t.TempDir()
+		var archiveFile = tmpDir + "/evil.tar.xz"
+		writeTestArchive(t, archiveFile, utils.ArchiveFormatTarXz, []*testArchiveEntry{entry})
+		err := utils.NewArchiveExtractor(archiveFile).ExtractTo(tmpDir + "/target")
+		if err == nil {
+			t.Fatal("entry '" + entry.header.Name + "' should be refused")
+		}
+		t.Log(err)
+		_, err = os.Lstat(filepath.Join(tmpDir, "evil"))
+		if err == nil {
+			t.Fatal("file should not be created outside of target dir")
+		}
+	}
+}
+
+func TestDetectArchiveArch(t *testing.T) {
+	// ELF64 header of x86_64 and aarch64 executables
+	var elfHeader = func(machine byte) string {
+		return "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00" + string([]byte{machine, 0}) + "\x01\x00\x00\x00"
+	}
+
+	var dir = t.TempDir()
+	for machine, arch := range map[byte]string{0x3e: "x86_64", 0xb7: "aarch64"} {
+		var file = dir + "/mysql-" + arch + ".tar.xz"
+		writeTestArchive(t, file, utils.ArchiveFormatTarXz, []*testArchiveEntry{
+			{header: &tar.Header{Name: "mysql/", Typeflag: tar.TypeDir, Mode: 0755}},
+			{header: &tar.Header{Name: "mysql/bin/README", Typeflag: tar.TypeReg, Mode: 0644}, body: "not an executable"},
+			{header: &tar.Header{Name: "mysql/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755}, body: elfHeader(machine)},
+		})
+		detectedArch, err := utils.DetectArchiveArch(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detectedArch != arch {
+			t.Fatal("expected '" + arch + "', got '" + detectedArch + "'")
+		}
+	}
+
+	// no executables
+	var file = dir + "/empty.tar"
+	writeTestArchive(t, file, utils.ArchiveFormatTar, []*testArchiveEntry{
+		{header: &tar.Header{Name: "mysql/README", Typeflag: tar.TypeReg, Mode: 0644}, body: "readme"},
+	})
+	detectedArch, err := utils.DetectArchiveArch(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(detectedArch) != 0 {
+		t.Fatal("expected empty arch, got '" + detectedArch + "'")
+	}
+}
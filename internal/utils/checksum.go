@@ -0,0 +1,84 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// FileChecksums calculate md5 and sha256 checksums of file in one pass
+func FileChecksums(file string) (md5Sum string, sha256Sum string, err error) {
+	fp, err := os.Open(file)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	var md5Hash = md5.New()
+	var sha256Hash = sha256.New()
+	_, err = io.Copy(io.MultiWriter(md5Hash, sha256Hash), fp)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
+// VerifyChecksums verify md5 and sha256 checksums of file, empty checksum will be ignored
+func VerifyChecksums(file string, expectedMD5 string, expectedSHA256 string) error {
+	if len(expectedMD5) == 0 && len(expectedSHA256) == 0 {
+		return nil
+	}
+
+	md5Sum, sha256Sum, err := FileChecksums(file)
+	if err != nil {
+		return errors.New("calculate checksums failed: " + err.Error())
+	}
+	if len(expectedMD5) > 0 && !strings.EqualFold(md5Sum, strings.TrimSpace(expectedMD5)) {
+		return errors.New("md5 checksum mismatch, expected '" + expectedMD5 + "', but got '" + md5Sum + "'")
+	}
+	if len(expectedSHA256) > 0 && !strings.EqualFold(sha256Sum, strings.TrimSpace(expectedSHA256)) {
+		return errors.New("sha256 checksum mismatch, expected '" + expectedSHA256 + "', but got '" + sha256Sum + "'")
+	}
+	return nil
+}
+
+// VerifySignature verify detached OpenPGP signature of file with public key
+// both armored and binary signatures and keys are supported
+func VerifySignature(file string, signature []byte, publicKey []byte) error {
+	keyRing, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(publicKey))
+	if err != nil {
+		keyRing, err = openpgp.ReadKeyRing(bytes.NewReader(publicKey))
+		if err != nil {
+			return errors.New("read public key failed: " + err.Error())
+		}
+	}
+
+	fp, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fp.Close()
+	}()
+
+	if bytes.Contains(signature, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyRing, fp, bytes.NewReader(signature), nil)
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyRing, fp, bytes.NewReader(signature), nil)
+	}
+	if err != nil {
+		return errors.New("invalid signature: " + err.Error())
+	}
+	return nil
+}
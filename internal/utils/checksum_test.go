@@ -0,0 +1,78 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils_test
+
+import (
+	"bytes"
+	"foolishmysql/internal/utils"
+	"os"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+func TestVerifyChecksums(t *testing.T) {
+	var file = t.TempDir() + "/hello.txt"
+	err := os.WriteFile(file, []byte("hello"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = utils.VerifyChecksums(file, "5d41402abc4b2a76b9719d911017c592", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = utils.VerifyChecksums(file, "", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9825")
+	if err == nil {
+		t.Fatal("checksum mismatch should be detected")
+	}
+	t.Log(err)
+}
+
+func TestVerifySignature(t *testing.T) {
+	var file = t.TempDir() + "/hello.txt"
+	err := os.WriteFile(file, []byte("hello"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var publicKey = &bytes.Buffer{}
+	keyWriter, err := armor.Encode(publicKey, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = entity.Serialize(keyWriter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = keyWriter.Close()
+
+	var signature = &bytes.Buffer{}
+	err = openpgp.ArmoredDetachSign(signature, entity, bytes.NewReader([]byte("hello")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = utils.VerifySignature(file, signature.Bytes(), publicKey.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tampered file
+	err = os.WriteFile(file, []byte("hello!"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = utils.VerifySignature(file, signature.Bytes(), publicKey.Bytes())
+	if err == nil {
+		t.Fatal("tampered file should not pass verification")
+	}
+	t.Log(err)
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -20,6 +21,7 @@ type Cmd struct {
 	ctx        context.Context
 	timeout    time.Duration
 	cancelFunc func()
+	done       chan struct{} // closed after process exited
 
 	captureStdout bool
 	captureStderr bool
@@ -47,13 +49,30 @@ func NewTimeoutCmd(timeout time.Duration, name string, args ...string) *Cmd {
 func (this *Cmd) WithTimeout(timeout time.Duration) *Cmd {
 	this.timeout = timeout
 
-	ctx, cancelFunc := context.WithTimeout(context.Background(), timeout)
+	var parentCtx = this.ctx
+	if parentCtx == nil {
+		parentCtx = context.Background()
+	}
+	ctx, cancelFunc := context.WithTimeout(parentCtx, timeout)
 	this.ctx = ctx
 	this.cancelFunc = cancelFunc
 
 	return this
 }
 
+// WithContext kill the process when context is done
+func (this *Cmd) WithContext(ctx context.Context) *Cmd {
+	if this.cancelFunc != nil {
+		this.cancelFunc()
+		this.cancelFunc = nil
+	}
+	this.ctx = ctx
+	if this.timeout > 0 {
+		return this.WithTimeout(this.timeout)
+	}
+	return this
+}
+
 func (this *Cmd) WithStdout() *Cmd {
 	this.captureStdout = true
 	return this
@@ -75,13 +94,42 @@ func (this *Cmd) WithDir(dir string) *Cmd {
 }
 
 func (this *Cmd) Start() error {
+	if this.ctx != nil && this.ctx.Err() != nil {
+		return this.ctx.Err()
+	}
+
 	var cmd = this.compose()
-	return cmd.Start()
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	// kill the whole process group after context is done,
+	// or processes forked by the command may keep stdout and stderr open, and Wait() never returns
+	if this.ctx != nil {
+		var ctx = this.ctx
+		var done = make(chan struct{})
+		var pid = cmd.Process.Pid
+		this.done = done
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = syscall.Kill(-pid, syscall.SIGKILL)
+			case <-done:
+			}
+		}()
+	}
+	return nil
 }
 
 func (this *Cmd) Wait() error {
 	var cmd = this.compose()
-	return cmd.Wait()
+	err := cmd.Wait()
+	if this.done != nil {
+		close(this.done)
+		this.done = nil
+	}
+	return err
 }
 
 func (this *Cmd) Run() error {
@@ -89,8 +137,11 @@ func (this *Cmd) Run() error {
 		defer this.cancelFunc()
 	}
 
-	var cmd = this.compose()
-	return cmd.Run()
+	err := this.Start()
+	if err != nil {
+		return err
+	}
+	return this.Wait()
 }
 
 func (this *Cmd) RawStdout() string {
@@ -135,11 +186,7 @@ func (this *Cmd) compose() *exec.Cmd {
 		return this.rawCmd
 	}
 
-	if this.ctx != nil {
-		this.rawCmd = exec.CommandContext(this.ctx, this.name, this.args...)
-	} else {
-		this.rawCmd = exec.Command(this.name, this.args...)
-	}
+	this.rawCmd = exec.Command(this.name, this.args...)
 
 	if this.env != nil {
 		this.rawCmd.Env = this.env
@@ -149,6 +196,10 @@ func (this *Cmd) compose() *exec.Cmd {
 		this.rawCmd.Dir = this.dir
 	}
 
+	// run in a new process group, so signals from terminal are not sent to it directly,
+	// and the caller can stop it in order
+	this.rawCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
 	if this.captureStdout {
 		this.stdout = &bytes.Buffer{}
 		this.rawCmd.Stdout = this.stdout
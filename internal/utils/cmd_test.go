@@ -0,0 +1,53 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils_test
+
+import (
+	"context"
+	"foolishmysql/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestCmd_WithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+
+	// forked process holds stdout, it should be killed too
+	var cmd = utils.NewCmd("sh", "-c", "sleep 30 & sleep 30").
+		WithContext(ctx).
+		WithStdout()
+	var before = time.Now()
+	err := cmd.Run()
+	if err == nil {
+		t.Fatal("command should be killed")
+	}
+	if time.Since(before) > 5*time.Second {
+		t.Fatal("command should be killed after context canceled")
+	}
+
+	// canceled context
+	err = utils.NewCmd("true").WithContext(ctx).Run()
+	if err != context.Canceled {
+		t.Fatal("expect canceled error, but got:", err)
+	}
+}
+
+func TestCmd_WithTimeout(t *testing.T) {
+	var cmd = utils.NewCmd("sleep", "30").
+		WithContext(context.Background()).
+		WithTimeout(500 * time.Millisecond)
+	var before = time.Now()
+	err := cmd.Run()
+	if err == nil {
+		t.Fatal("command should be killed")
+	}
+	if time.Since(before) > 5*time.Second {
+		t.Fatal("command should be killed after timeout")
+	}
+
+	err = utils.NewTimeoutCmd(5*time.Second, "true").Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var ErrDownloadNotFound = errors.New("file not found on server")
+
+var contentRangeReg = regexp.MustCompile(`^bytes (\d+)-\d+/(\d+|\*)$`)
+
+// Downloader download file into '${path}.part', resume with Range requests after failures,
+// and rename it to the target path after completed
+type Downloader struct {
+	url     string
+	headers map[string]string
+	ctx     context.Context
+
+	client         *http.Client
+	proxy          string
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+	maxRetries     int
+
+	written int64
+	total   int64
+
+	onProgress func(written int64, total int64)
+}
+
+func NewDownloader(url string) *Downloader {
+	return &Downloader{
+		url:            url,
+		headers:        map[string]string{},
+		ctx:            context.Background(),
+		connectTimeout: 10 * time.Second,
+		readTimeout:    30 * time.Second,
+		maxRetries:     5,
+	}
+}
+
+// WithClient set custom http client, timeouts set by WithTimeouts will not be applied to it
+func (this *Downloader) WithClient(client *http.Client) *Downloader {
+	this.client = client
+	return this
+}
+
+func (this *Downloader) WithHeader(name string, value string) *Downloader {
+	this.headers[name] = value
+	return this
+}
+
+// WithContext set context to cancel downloading, downloaded bytes are kept in part file
+func (this *Downloader) WithContext(ctx context.Context) *Downloader {
+	this.ctx = ctx
+	return this
+}
+
+// WithProxy set proxy url, HTTP_PROXY and HTTPS_PROXY environment variables are used if proxy is empty
+func (this *Downloader) WithProxy(proxy string) *Downloader {
+	this.proxy = proxy
+	return this
+}
+
+// WithTimeouts set connect timeout and read timeout
+// read timeout is the max duration waiting for next bytes from server
+func (this *Downloader) WithTimeouts(connectTimeout time.Duration, readTimeout time.Duration) *Downloader {
+	this.connectTimeout = connectTimeout
+	this.readTimeout = readTimeout
+	return this
+}
+
+// WithRetries set max retries after failures
+func (this *Downloader) WithRetries(maxRetries int) *Downloader {
+	this.maxRetries = maxRetries
+	return this
+}
+
+// OnProgress set callback of progress, total is -1 if it is unknown
+// callback is called in downloading goroutine after bytes are written
+func (this *Downloader) OnProgress(callback func(written int64, total int64)) *Downloader {
+	this.onProgress = callback
+	return this
+}
+
+// Progress get download progress between 0 and 1
+func (this *Downloader) Progress() float32 {
+	var total = atomic.LoadInt64(&this.total)
+	if total <= 0 {
+		return 0
+	}
+	return float32(float64(atomic.LoadInt64(&this.written)) / float64(total))
+}
+
+// DownloadTo download file to the path
+func (this *Downloader) DownloadTo(path string) error {
+	var partFile = path + ".part"
+	var lastErr error
+	for i := 0; i <= this.maxRetries; i++ {
+		if i > 0 {
+			// backoff: 1s, 2s, 4s ..., 30s at most
+			var backoff = time.Duration(1<<(i-1)) * time.Second
+			if backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			select {
+			case <-this.ctx.Done():
+				return this.ctx.Err()
+			case <-time.After(backoff):
+			}
+		}
+
+		completed, err := this.downloadOnce(partFile)
+		if err != nil {
+			if err == ErrDownloadNotFound {
+				return err
+			}
+			if this.ctx.Err() != nil {
+				return this.ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		if !completed {
+			continue
+		}
+
+		return os.Rename(partFile, path)
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("download incomplete")
+	}
+	return errors.New("download failed after " + strconv.Itoa(this.maxRetries) + " retries: " + lastErr.Error())
+}
+
+// download remaining bytes into part file
+func (this *Downloader) downloadOnce(partFile string) (completed bool, err error) {
+	var offset int64 = 0
+	stat, err := os.Stat(partFile)
+	if err == nil {
+		offset = stat.Size()
+	}
+
+	ctx, cancel := context.WithCancel(this.ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, this.url, nil)
+	if err != nil {
+		return false, err
+	}
+	for name, value := range this.headers {
+		req.Header.Set(name, value)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := this.httpClient().Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var flags = os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// server does not support Range, start over
+		offset = 0
+		flags |= os.O_TRUNC
+		atomic.StoreInt64(&this.total, resp.ContentLength)
+	case http.StatusPartialContent:
+		var matches = contentRangeReg.FindStringSubmatch(resp.Header.Get("Content-Range"))
+		if len(matches) == 0 {
+			return false, errors.New("invalid Content-Range '" + resp.Header.Get("Content-Range") + "'")
+		}
+		start, _ := strconv.ParseInt(matches[1], 10, 64)
+		if start != offset {
+			return false, errors.New("invalid Content-Range '" + resp.Header.Get("Content-Range") + "', expected offset " + strconv.FormatInt(offset, 10))
+		}
+		var total int64 = -1
+		if matches[2] != "*" {
+			total, _ = strconv.ParseInt(matches[2], 10, 64)
+		}
+		atomic.StoreInt64(&this.total, total)
+		flags |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		// part file may be completed already, or larger than the file on server
+		var total = this.parseTotalFromUnsatisfiedRange(resp.Header.Get("Content-Range"))
+		if total >= 0 && total == offset {
+			atomic.StoreInt64(&this.total, total)
+			atomic.StoreInt64(&this.written, total)
+			if this.onProgress != nil {
+				this.onProgress(total, total)
+			}
+			return true, nil
+		}
+		_ = os.Remove(partFile)
+		return false, errors.New("invalid part file, removed it")
+	case http.StatusNotFound:
+		return false, ErrDownloadNotFound
+	default:
+		return false, errors.New("invalid response code: " + strconv.Itoa(resp.StatusCode))
+	}
+
+	fp, err := os.OpenFile(partFile, flags, 0666)
+	if err != nil {
+		return false, err
+	}
+	atomic.StoreInt64(&this.written, offset)
+
+	// cancel request if no bytes received within read timeout
+	var timer = time.AfterFunc(this.readTimeout, cancel)
+	var buf = make([]byte, 32*1024)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			timer.Reset(this.readTimeout)
+			_, err = fp.Write(buf[:n])
+			if err != nil {
+				timer.Stop()
+				_ = fp.Close()
+				return false, err
+			}
+			var written = atomic.AddInt64(&this.written, int64(n))
+			if this.onProgress != nil {
+				this.onProgress(written, atomic.LoadInt64(&this.total))
+			}
+		}
+		if readErr != nil {
+			timer.Stop()
+			if readErr != io.EOF {
+				_ = fp.Close()
+				return false, readErr
+			}
+			break
+		}
+	}
+
+	err = fp.Close()
+	if err != nil {
+		return false, err
+	}
+
+	// verify length
+	var total = atomic.LoadInt64(&this.total)
+	var written = atomic.LoadInt64(&this.written)
+	if total >= 0 && written != total {
+		return false, errors.New("file length mismatch, expected " + strconv.FormatInt(total, 10) + " bytes, but got " + strconv.FormatInt(written, 10) + " bytes")
+	}
+
+	return true, nil
+}
+
+func (this *Downloader) httpClient() *http.Client {
+	if this.client != nil {
+		return this.client
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: ProxyFunc(this.proxy),
+			DialContext: (&net.Dialer{
+				Timeout:   this.connectTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   this.connectTimeout,
+			ResponseHeaderTimeout: this.readTimeout,
+		},
+	}
+}
+
+// parse 'bytes */TOTAL'
+func (this *Downloader) parseTotalFromUnsatisfiedRange(contentRange string) int64 {
+	var reg = regexp.MustCompile(`^bytes \*/(\d+)$`)
+	var matches = reg.FindStringSubmatch(contentRange)
+	if len(matches) == 0 {
+		return -1
+	}
+	total, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
+}
+
+// ProxyFunc build proxy function for http transport
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used if proxy is empty
+func ProxyFunc(proxy string) func(req *http.Request) (*url.URL, error) {
+	if len(proxy) == 0 {
+		return http.ProxyFromEnvironment
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		return url.Parse(proxy)
+	}
+}
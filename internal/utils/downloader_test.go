@@ -0,0 +1,121 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils_test
+
+import (
+	"bytes"
+	"context"
+	"foolishmysql/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDownloader_DownloadTo(t *testing.T) {
+	var content = bytes.Repeat([]byte("0123456789"), 100*1024)
+	var requests = 0
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.URL.Path != "/mysql.tar.xz" {
+			http.NotFound(writer, req)
+			return
+		}
+
+		// drop connection at the middle of the first request
+		if requests == 1 {
+			writer.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			writer.WriteHeader(http.StatusOK)
+			_, _ = writer.Write(content[:len(content)/2])
+			writer.(http.Flusher).Flush()
+			conn, _, err := writer.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+
+		if req.Header.Get("Range") != "bytes="+strconv.Itoa(len(content)/2)+"-" {
+			t.Error("expected range request, but got '" + req.Header.Get("Range") + "'")
+		}
+		http.ServeContent(writer, req, "mysql.tar.xz", time.Now(), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	var path = t.TempDir() + "/mysql.tar.xz"
+	var downloader = utils.NewDownloader(server.URL+"/mysql.tar.xz").
+		WithTimeouts(1*time.Second, 1*time.Second).
+		WithRetries(2)
+	var lastWritten, lastTotal int64
+	downloader.OnProgress(func(written int64, total int64) {
+		lastWritten, lastTotal = written, total
+	})
+	err := downloader.DownloadTo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastWritten != int64(len(content)) || lastTotal != int64(len(content)) {
+		t.Fatal("invalid progress callback:", lastWritten, lastTotal)
+	}
+	if requests != 2 {
+		t.Fatal("expect 2 requests, but got", requests)
+	}
+	if downloader.Progress() != 1 {
+		t.Fatal("invalid progress:", downloader.Progress())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatal("content mismatch")
+	}
+	_, err = os.Stat(path + ".part")
+	if err == nil {
+		t.Fatal("part file should be renamed")
+	}
+
+	// not found
+	err = utils.NewDownloader(server.URL + "/not-found.tar.xz").DownloadTo(t.TempDir() + "/not-found.tar.xz")
+	if err != utils.ErrDownloadNotFound {
+		t.Fatal("expect not found error, but got:", err)
+	}
+}
+
+func TestDownloader_Cancel(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Header().Set("Content-Length", "1024")
+		writer.WriteHeader(http.StatusOK)
+		_, _ = writer.Write(make([]byte, 512))
+		writer.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var path = t.TempDir() + "/mysql.tar.xz"
+	var downloader = utils.NewDownloader(server.URL+"/mysql.tar.xz").
+		WithContext(ctx).
+		WithTimeouts(1*time.Second, 10*time.Second)
+	downloader.OnProgress(func(written int64, total int64) {
+		cancel()
+	})
+
+	var before = time.Now()
+	err := downloader.DownloadTo(path)
+	if err != context.Canceled {
+		t.Fatal("expect canceled error, but got:", err)
+	}
+	if time.Since(before) > 5*time.Second {
+		t.Fatal("download should be stopped immediately after canceled")
+	}
+
+	// downloaded bytes are kept to resume
+	stat, err := os.Stat(path + ".part")
+	if err != nil || stat.Size() != 512 {
+		t.Fatal("part file should be kept")
+	}
+}
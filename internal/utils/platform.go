@@ -0,0 +1,87 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	ArchX86_64  = "x86_64"
+	ArchAarch64 = "aarch64"
+)
+
+var lddVersionReg = regexp.MustCompile(`(\d+\.\d+)\s*$`)
+var libcFileVersionReg = regexp.MustCompile(`^libc-(\d+\.\d+)\.so$`)
+var libcBannerReg = regexp.MustCompile(`GNU C Library[^\n]*?version (\d+\.\d+)`)
+
+// HostArch get architecture name of current host used in mysql package names
+func HostArch() (string, error) {
+	switch runtime.GOARCH {
+	case "amd64":
+		return ArchX86_64, nil
+	case "arm64":
+		return ArchAarch64, nil
+	}
+	return "", errors.New("unsupported architecture '" + runtime.GOARCH + "', only x86_64 and aarch64 are supported")
+}
+
+// HostGlibcVersion get glibc version of current host, such as '2.28'
+// read from 'ldd --version' first, then from '/lib*/libc.so.6'
+func HostGlibcVersion() (string, error) {
+	var cmd = NewTimeoutCmd(5*time.Second, "ldd", "--version").WithStdout().WithStderr()
+	if cmd.Run() == nil {
+		var output = cmd.Stdout()
+		if strings.Contains(strings.ToLower(output), "musl") {
+			return "", errors.New("musl libc is not supported, mysql requires glibc")
+		}
+		var firstLine = strings.SplitN(output, "\n", 2)[0]
+		var matches = lddVersionReg.FindStringSubmatch(firstLine)
+		if len(matches) > 0 {
+			return matches[1], nil
+		}
+	}
+
+	for _, pattern := range []string{"/lib*/libc.so.6", "/lib/*/libc.so.6", "/usr/lib*/libc.so.6", "/usr/lib/*/libc.so.6"} {
+		files, _ := filepath.Glob(pattern)
+		for _, file := range files {
+			var version = libcFileVersion(file)
+			if len(version) > 0 {
+				return version, nil
+			}
+		}
+	}
+
+	return "", errors.New("could not detect glibc version")
+}
+
+// read glibc version from libc file
+func libcFileVersion(file string) string {
+	// older distributions: libc.so.6 -> libc-2.17.so
+	realFile, err := filepath.EvalSymlinks(file)
+	if err == nil {
+		var matches = libcFileVersionReg.FindStringSubmatch(filepath.Base(realFile))
+		if len(matches) > 0 {
+			return matches[1]
+		}
+	} else {
+		realFile = file
+	}
+
+	// newer distributions: version is in the banner string
+	data, err := os.ReadFile(realFile)
+	if err != nil {
+		return ""
+	}
+	var matches = libcBannerReg.FindSubmatch(data)
+	if len(matches) > 0 {
+		return string(matches[1])
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package utils_test
+
+import (
+	"foolishmysql/internal/utils"
+	"testing"
+)
+
+func TestHostArch(t *testing.T) {
+	arch, err := utils.HostArch()
+	if err != nil {
+		t.Log("skip:", err)
+		return
+	}
+	t.Log("arch:", arch)
+}
+
+func TestHostGlibcVersion(t *testing.T) {
+	version, err := utils.HostGlibcVersion()
+	if err != nil {
+		t.Log("skip:", err)
+		return
+	}
+	t.Log("glibc:", version)
+}
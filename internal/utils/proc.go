@@ -38,6 +38,150 @@ func FindPidWithName(name string) int {
 	return 0
 }
 
+// FindPidsWithName find all processes with the name
+func FindPidsWithName(name string) []int {
+	var result = []int{}
+
+	commFiles, err := filepath.Glob(ProcDir + "/*/comm")
+	if err != nil {
+		return result
+	}
+
+	for _, commFile := range commFiles {
+		data, err := os.ReadFile(commFile)
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(string(data)) == name {
+			var pieces = strings.Split(commFile, "/")
+			pidInt, err := strconv.Atoi(pieces[len(pieces)-2])
+			if err == nil && pidInt > 0 {
+				result = append(result, pidInt)
+			}
+		}
+	}
+
+	return result
+}
+
+// ProcessCwd read current working directory of process
+func ProcessCwd(pid int) string {
+	cwd, err := os.Readlink(ProcDir + "/" + strconv.Itoa(pid) + "/cwd")
+	if err != nil {
+		return ""
+	}
+	return cwd
+}
+
+// ProcessParentPid read parent pid of process, 0 if process does not exist
+func ProcessParentPid(pid int) int {
+	var fields = processStatFields(pid)
+	if len(fields) < 2 {
+		return 0
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	return ppid
+}
+
+// IsProcessAlive check whether the process exists and is not a zombie
+func IsProcessAlive(pid int) bool {
+	var fields = processStatFields(pid)
+	return len(fields) > 0 && fields[0] != "Z" && fields[0] != "X"
+}
+
+// IsDescendantProcess check whether the process is a child or grandchild of ancestor
+func IsDescendantProcess(pid int, ancestorPid int) bool {
+	for i := 0; i < 64 && pid > 1; i++ {
+		pid = ProcessParentPid(pid)
+		if pid == ancestorPid {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessListensOnPort check whether the process owns a listening tcp socket on the port
+func ProcessListensOnPort(pid int, port int) bool {
+	// inodes of listening sockets on the port
+	var inodes = map[string]bool{}
+	for _, file := range []string{ProcDir + "/net/tcp", ProcDir + "/net/tcp6"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+			var fields = strings.Fields(line)
+			if len(fields) < 10 || fields[3] != "0A" {
+				continue
+			}
+			var index = strings.LastIndex(fields[1], ":")
+			if index < 0 {
+				continue
+			}
+			localPort, err := strconv.ParseInt(fields[1][index+1:], 16, 32)
+			if err == nil && int(localPort) == port {
+				inodes[fields[9]] = true
+			}
+		}
+	}
+	return processOwnsSocket(pid, inodes)
+}
+
+// ProcessListensOnUnixSocket check whether the process owns a listening unix socket bound to the path
+func ProcessListensOnUnixSocket(pid int, path string) bool {
+	data, err := os.ReadFile(ProcDir + "/net/unix")
+	if err != nil {
+		return false
+	}
+
+	// inodes of listening sockets on the path
+	var inodes = map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n")[1:] {
+		// Num RefCount Protocol Flags Type St Inode Path
+		var fields = strings.Fields(line)
+		if len(fields) < 8 || fields[3] != "00010000" || fields[7] != path {
+			continue
+		}
+		inodes[fields[6]] = true
+	}
+	return processOwnsSocket(pid, inodes)
+}
+
+// check whether one of file descriptors of the process is a socket with the inodes
+func processOwnsSocket(pid int, inodes map[string]bool) bool {
+	if len(inodes) == 0 {
+		return false
+	}
+
+	entries, err := os.ReadDir(ProcDir + "/" + strconv.Itoa(pid) + "/fd")
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		link, err := os.Readlink(ProcDir + "/" + strconv.Itoa(pid) + "/fd/" + entry.Name())
+		if err == nil && strings.HasPrefix(link, "socket:[") && inodes[strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")] {
+			return true
+		}
+	}
+	return false
+}
+
+// fields of '/proc/${pid}/stat' after process name, starting with state
+func processStatFields(pid int) []string {
+	data, err := os.ReadFile(ProcDir + "/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return nil
+	}
+
+	// process name may contain spaces and parentheses
+	var index = bytes.LastIndexByte(data, ')')
+	if index < 0 {
+		return nil
+	}
+	return strings.Fields(string(data[index+1:]))
+}
+
 func SysMemoryGB() int {
 	if runtime.GOOS != "linux" {
 		return 0
@@ -4,9 +4,81 @@ package utils_test
 
 import (
 	"foolishmysql/internal/utils"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 )
 
 func TestSysMemoryGB(t *testing.T) {
 	t.Log(utils.SysMemoryGB())
 }
+
+func TestProcessCwd(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utils.ProcessCwd(os.Getpid()) != cwd {
+		t.Fatal("expect '" + cwd + "', but got '" + utils.ProcessCwd(os.Getpid()) + "'")
+	}
+}
+
+func TestProcessListensOnPort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	var port = listener.Addr().(*net.TCPAddr).Port
+	if !utils.ProcessListensOnPort(os.Getpid(), port) {
+		t.Fatal("current process should listen on port", port)
+	}
+	if utils.ProcessListensOnPort(os.Getppid(), port) {
+		t.Fatal("parent process should not listen on port", port)
+	}
+}
+
+func TestProcessListensOnUnixSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	var socket = filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	if !utils.ProcessListensOnUnixSocket(os.Getpid(), socket) {
+		t.Fatal("current process should listen on '" + socket + "'")
+	}
+	if utils.ProcessListensOnUnixSocket(os.Getppid(), socket) {
+		t.Fatal("parent process should not listen on '" + socket + "'")
+	}
+}
+
+func TestIsDescendantProcess(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if utils.ProcessParentPid(os.Getpid()) != os.Getppid() {
+		t.Fatal("unexpected parent pid", utils.ProcessParentPid(os.Getpid()))
+	}
+	if !utils.IsProcessAlive(os.Getpid()) {
+		t.Fatal("current process should be alive")
+	}
+	if !utils.IsDescendantProcess(os.Getpid(), os.Getppid()) || utils.IsDescendantProcess(os.Getppid(), os.Getpid()) {
+		t.Fatal("unexpected descendant")
+	}
+}
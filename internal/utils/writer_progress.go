@@ -23,6 +23,16 @@ func (this *ProgressWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// Written bytes written to raw writer
+func (this *ProgressWriter) Written() int64 {
+	return this.written
+}
+
+// Total expected total bytes
+func (this *ProgressWriter) Total() int64 {
+	return this.total
+}
+
 func (this *ProgressWriter) Progress() float32 {
 	if this.total <= 0 {
 		return 0
@@ -0,0 +1,264 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+// Package installer install MySQL Community Server from official packages or local archives.
+package installer
+
+import (
+	"context"
+	"errors"
+	"foolishmysql/internal/installers"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// AllSteps installation steps in order, download step is not included
+var AllSteps = installers.AllSteps
+
+// Result result of installation
+type Result struct {
+	*InstallResult
+
+	RootPassword string
+	Users        []*UserConfig // created users, PasswordGenerated is true if password was generated
+	Plan         *Plan         // planned changes in dry-run mode
+	RolledBack   []string      // rolled back changes after installation failed
+	StateFile    string        // state file to resume failed installation, empty in dry-run mode
+}
+
+// Install download and install mysql
+// installation is stopped and rolled back after ctx is canceled, and ctx.Err() is returned
+// result is also returned with error, to get rolled back changes and state file
+func Install(ctx context.Context, opts *Options) (*Result, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	var result = &Result{}
+	var reporter = reporterOf(opts)
+	installer, err := newInstaller(ctx, opts, reporter)
+	if err != nil {
+		return result, err
+	}
+
+	err = install(ctx, installer, opts, reporter)
+	result.InstallResult = installer.Result()
+	result.RootPassword = installer.Password()
+	result.Users = installer.Users()
+	if opts.DryRun {
+		result.Plan = installer.Plan()
+	} else if err != nil {
+		_, statErr := os.Stat(installer.StateFile())
+		if statErr == nil {
+			result.StateFile = installer.StateFile()
+		}
+	}
+	if err != nil {
+		result.RolledBack = installer.RolledBack()
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+// download and install
+func install(ctx context.Context, installer *installers.FoolishInstaller, opts *Options, reporter Reporter) error {
+	if opts.Resume {
+		err := installer.Resume()
+		if err != nil {
+			return errors.New("resume installation failed: " + err.Error())
+		}
+		return nil
+	}
+
+	var baseDir = opts.BaseDir
+	if len(baseDir) == 0 {
+		if len(opts.Instance) > 0 {
+			baseDir = DefaultBaseDir + "-" + opts.Instance
+		} else {
+			baseDir = DefaultBaseDir
+		}
+	}
+	baseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return errors.New("invalid dir '" + opts.BaseDir + "': " + err.Error())
+	}
+
+	// check target dir
+	_, err = os.Stat(baseDir)
+	if err == nil && len(opts.OnlySteps) == 0 {
+		matches, _ := filepath.Glob(baseDir + "/*")
+		if len(matches) > 0 {
+			return errors.New("target dir '" + baseDir + "' already exists and not empty, please check if you are using the directory")
+		}
+	}
+
+	var archivePath = opts.Archive
+	if len(archivePath) == 0 {
+		archivePath, err = download(ctx, installer, reporter)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = installer.InstallFromFile(archivePath, baseDir)
+	if err != nil {
+		return errors.New("install from file '" + archivePath + "' failed: " + err.Error())
+	}
+	return nil
+}
+
+// download archive, and report it as a step
+func download(ctx context.Context, installer *installers.FoolishInstaller, reporter Reporter) (string, error) {
+	reporter.StepStarted(StepDownload)
+	var startedAt = time.Now()
+	archivePath, err := installer.Download()
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		reporter.StepFinished(&StepEvent{Name: StepDownload, Status: StepStatusFailed, Duration: time.Since(startedAt).Seconds(), Error: err.Error()})
+		return "", errors.New("download failed: " + err.Error())
+	}
+	reporter.StepFinished(&StepEvent{Name: StepDownload, Status: StepStatusCompleted, Duration: time.Since(startedAt).Seconds()})
+	return archivePath, nil
+}
+
+// create installer with options
+func newInstaller(ctx context.Context, opts *Options, reporter Reporter) (*installers.FoolishInstaller, error) {
+	var installer = installers.NewFoolishInstaller(installers.WithReporter(reporter)).
+		WithContext(ctx).
+		WithDryRun(opts.DryRun)
+
+	if len(opts.StateDir) > 0 {
+		installer.WithStateDir(opts.StateDir)
+	}
+
+	// steps
+	var skipSteps = append([]StepName{}, opts.SkipSteps...)
+	if opts.NoService {
+		skipSteps = append(skipSteps, StepService)
+	}
+	for _, steps := range [][]StepName{opts.OnlySteps, skipSteps} {
+		err := installers.ValidateSteps(steps)
+		if err != nil {
+			return nil, err
+		}
+	}
+	installer.WithSteps(opts.OnlySteps, skipSteps)
+
+	// source
+	if len(opts.Flavour) > 0 {
+		if opts.Flavour != FlavourMinimal && opts.Flavour != FlavourFull {
+			return nil, errors.New("invalid flavour '" + opts.Flavour + "', should be '" + FlavourMinimal + "' or '" + FlavourFull + "'")
+		}
+		installer.WithFlavour(opts.Flavour)
+	}
+	if len(opts.Version) > 0 {
+		_, _, err := installers.ParseVersionSelector(opts.Version)
+		if err != nil {
+			return nil, err
+		}
+		installer.WithVersion(opts.Version)
+	}
+	if len(opts.Mirror) > 0 {
+		mirror, err := installers.NewMirror(opts.Mirror)
+		if err != nil {
+			return nil, err
+		}
+		installer.WithMirror(mirror)
+	}
+	installer.WithProxy(opts.Proxy)
+	if len(opts.CacheDir) > 0 {
+		installer.WithCache(installers.NewCache(opts.CacheDir))
+	}
+	installer.WithChecksums(opts.MD5, opts.SHA256)
+	installer.WithSkipChecksum(opts.SkipChecksum)
+	if opts.VerifySignature || len(opts.Signature) > 0 || len(opts.GPGKey) > 0 {
+		installer.WithSignature(opts.Signature, opts.GPGKey)
+	}
+
+	// server
+	if len(opts.Instance) > 0 {
+		err := installers.ValidateInstanceName(opts.Instance)
+		if err != nil {
+			return nil, err
+		}
+		installer.WithInstance(opts.Instance)
+	}
+	if opts.Port != 0 {
+		if opts.Port < 0 || opts.Port > 65535 {
+			return nil, errors.New("invalid port '" + strconv.Itoa(opts.Port) + "'")
+		}
+		installer.WithPort(opts.Port)
+	}
+	if len(opts.Socket) > 0 {
+		installer.WithSocket(opts.Socket)
+	}
+	if opts.StartTimeout < 0 {
+		return nil, errors.New("invalid start timeout '" + opts.StartTimeout.String() + "'")
+	}
+	if opts.StartTimeout > 0 {
+		installer.WithStartTimeout(opts.StartTimeout)
+	}
+
+	// dirs should be absolute, because they will be written into my.cnf
+	if len(opts.DataDir) > 0 {
+		dataDir, err := filepath.Abs(opts.DataDir)
+		if err != nil {
+			return nil, errors.New("invalid dir '" + opts.DataDir + "': " + err.Error())
+		}
+		installer.WithDataDir(dataDir)
+	}
+	if len(opts.TmpDir) > 0 {
+		tmpDir, err := filepath.Abs(opts.TmpDir)
+		if err != nil {
+			return nil, errors.New("invalid dir '" + opts.TmpDir + "': " + err.Error())
+		}
+		installer.WithTmpDir(tmpDir)
+	}
+
+	// options shared with config file
+	var hardening = !opts.NoHardening
+	var config = &installers.InstallConfig{
+		Port:         opts.Port,
+		RootPassword: &installers.RootPasswordConfig{Password: opts.RootPassword, Length: opts.PasswordLength},
+		Databases:    opts.Databases,
+		Users:        opts.Users,
+		MyCnf:        opts.MyCnf,
+		Service:      &installers.ServiceConfig{Restart: opts.ServiceRestart},
+		Hardening:    &installers.HardeningConfig{Enabled: &hardening, RootAuthSocket: opts.RootAuthSocket},
+		Dependencies: opts.Dependencies,
+	}
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+	installer.WithRootPassword(opts.RootPassword, opts.PasswordLength)
+	installer.WithMyCnfOptions(opts.MyCnf)
+	installer.WithServiceRestart(opts.ServiceRestart)
+	installer.WithDependencyPolicy(opts.Dependencies)
+	installer.WithDatabases(opts.Databases)
+	installer.WithUsers(opts.Users)
+	installer.WithHardening(hardening, opts.RootAuthSocket)
+
+	return installer, nil
+}
+
+// reporter of options, with hooks
+func reporterOf(opts *Options) Reporter {
+	var reporter = opts.Reporter
+	if reporter == nil {
+		reporter = NewConsoleReporter()
+	}
+	if opts.Hooks != nil {
+		return &hooksReporter{reporter: reporter, hooks: opts.Hooks}
+	}
+	return reporter
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installer_test
+
+import (
+	"archive/tar"
+	"context"
+	"foolishmysql/pkg/installer"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// create archive with a fake x86_64 executable
+func createArchive(t *testing.T) string {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("only for x86_64")
+	}
+
+	var archivePath = t.TempDir() + "/mysql-8.0.36-linux-glibc2.17-x86_64-minimal.tar"
+	fp, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var elfHeader = "\x7fELF\x02\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x02\x00\x3e\x00\x01\x00\x00\x00"
+	var writer = tar.NewWriter(fp)
+	err = writer.WriteHeader(&tar.Header{Name: "mysql-8.0.36-linux-glibc2.17-x86_64-minimal/bin/mysqld", Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(elfHeader))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = writer.Write([]byte(elfHeader))
+	_ = writer.Close()
+	_ = fp.Close()
+	return archivePath
+}
+
+func TestInstall_DryRun(t *testing.T) {
+	var archivePath = createArchive(t)
+	var targetDir = t.TempDir() + "/mysql"
+
+	var statuses = map[installer.StepName]installer.StepStatus{}
+	result, err := installer.Install(context.Background(), &installer.Options{
+		Archive:   archivePath,
+		BaseDir:   targetDir,
+		Instance:  "pkgtest",
+		Port:      33063,
+		Databases: []*installer.DatabaseConfig{{Name: "app"}},
+		NoService: true,
+		DryRun:    true,
+		Hooks: &installer.Hooks{
+			OnStepFinished: func(event *installer.StepEvent) {
+				statuses[event.Name] = event.Status
+			},
+		},
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "could not find") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+
+	if result.Plan == nil || len(result.Plan.Actions) == 0 {
+		t.Fatal("changes should be planned")
+	}
+	if result.BaseDir != targetDir || result.Port != 33063 || result.Socket != "/tmp/mysql-pkgtest.sock" || len(result.StateFile) > 0 {
+		t.Fatalf("invalid result: %+v", result.InstallResult)
+	}
+	if statuses[installer.StepPreflight] != installer.StepStatusCompleted || statuses[installer.StepService] != installer.StepStatusSkipped {
+		t.Fatal("invalid step events:", statuses)
+	}
+	_, err = os.Stat(targetDir)
+	if err == nil {
+		t.Fatal("'" + targetDir + "' should not be created in dry-run mode")
+	}
+}
+
+func TestInstall_Canceled(t *testing.T) {
+	var archivePath = createArchive(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := installer.Install(ctx, &installer.Options{
+		Archive:  archivePath,
+		BaseDir:  t.TempDir() + "/mysql",
+		StateDir: t.TempDir(),
+		Instance: "pkgtest",
+	})
+	if err != context.Canceled {
+		t.Fatal("expect canceled error, but got:", err)
+	}
+	if len(result.RolledBack) > 0 {
+		t.Fatal("nothing should be changed before the first step")
+	}
+}
+
+func TestInstall_InvalidOptions(t *testing.T) {
+	for _, opts := range []*installer.Options{
+		{Port: 65536},
+		{Instance: "a b"},
+		{Version: "x"},
+		{Flavour: "debug"},
+		{OnlySteps: []installer.StepName{"download"}},
+		{Users: []*installer.UserConfig{{Name: "root"}}},
+		{Port: 3307, MyCnf: map[string]string{"mysqlx_port": "3307"}},
+		{MyCnf: map[string]string{"mysqlx_port": "'3306'"}},
+	} {
+		_, err := installer.Install(context.Background(), opts)
+		if err == nil {
+			t.Fatalf("expect error of options: %+v", opts)
+		}
+		t.Log(err)
+	}
+}
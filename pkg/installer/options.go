@@ -0,0 +1,180 @@
+// Copyright 2023 Liuxiangchao iwind.liu@gmail.com. All rights reserved. Official site: https://goedge.cn .
+
+package installer
+
+import (
+	"foolishmysql/internal/installers"
+	"time"
+)
+
+type Flavour = installers.Flavour
+
+const (
+	FlavourMinimal = installers.FlavourMinimal
+	FlavourFull    = installers.FlavourFull
+)
+
+type DependencyPolicy = installers.DependencyPolicy
+
+const (
+	DependenciesInstall = installers.DependenciesInstall // install missing shared libraries with apt or yum
+	DependenciesCheck   = installers.DependenciesCheck   // fail if shared libraries are missing
+	DependenciesSkip    = installers.DependenciesSkip    // do not check shared libraries
+)
+
+type StepName = installers.StepName
+
+const (
+	StepDownload     StepName = "download" // download archive, only reported if Options.Archive is empty
+	StepPreflight             = installers.StepPreflight
+	StepDependencies          = installers.StepDependencies
+	StepUser                  = installers.StepUser
+	StepExtract               = installers.StepExtract
+	StepConfigure             = installers.StepConfigure
+	StepInitialize            = installers.StepInitialize
+	StepMove                  = installers.StepMove
+	StepStart                 = installers.StepStart
+	StepSecure                = installers.StepSecure
+	StepHarden                = installers.StepHarden
+	StepAccounts              = installers.StepAccounts
+	StepLink                  = installers.StepLink
+	StepService               = installers.StepService
+)
+
+type StepStatus = installers.StepStatus
+
+const (
+	StepStatusStarted   = installers.StepStatusStarted
+	StepStatusCompleted = installers.StepStatusCompleted
+	StepStatusFailed    = installers.StepStatusFailed
+	StepStatusSkipped   = installers.StepStatusSkipped
+)
+
+type StepEvent = installers.StepEvent
+type Reporter = installers.Reporter
+type ConsoleReporter = installers.ConsoleReporter
+type DatabaseConfig = installers.DatabaseConfig
+type UserConfig = installers.UserConfig
+type GrantConfig = installers.GrantConfig
+type Plan = installers.Plan
+type PlanAction = installers.PlanAction
+type InstallResult = installers.InstallResult
+
+const (
+	DefaultBaseDir        = "/usr/local/mysql"
+	DefaultPort           = installers.DefaultPort
+	DefaultCacheDir       = installers.DefaultCacheDir
+	DefaultStateDir       = installers.DefaultStateDir
+	DefaultUserHost       = installers.DefaultUserHost
+	DefaultPasswordLength = installers.DefaultPasswordLength
+	DefaultStartTimeout   = installers.DefaultStartTimeout
+)
+
+// NewConsoleReporter create the default reporter which prints messages with standard log package
+// embed it to customize some events only
+func NewConsoleReporter() *ConsoleReporter {
+	return installers.NewConsoleReporter()
+}
+
+// Options options of Install(), zero values mean defaults
+type Options struct {
+	// source
+	Archive         string  // local archive file, tar.xz, tar.gz or tar, downloaded if empty
+	Version         string  // version selector to download, such as '8.0.36', or '8.4' for latest release of the series, default is the latest release
+	Flavour         Flavour // package flavour to download, default is FlavourMinimal
+	Mirror          string  // custom mirror url or local dir instead of mysql CDN
+	Proxy           string  // proxy url for downloading, default is from HTTP_PROXY and HTTPS_PROXY environment variables
+	CacheDir        string  // dir to keep downloaded packages, packages are downloaded into current dir if empty
+	MD5             string  // expected md5 checksum of archive
+	SHA256          string  // expected sha256 checksum of archive
+	VerifySignature bool    // verify GPG signature of archive
+	SkipChecksum    bool    // install downloaded archive even if no published checksum is found, it is rejected by default
+	Signature       string  // detached signature file, default is '${archive}.asc', implies VerifySignature
+	GPGKey          string  // public key to verify signature, default is the mysql release key, implies VerifySignature
+
+	// dirs
+	BaseDir  string // default is '/usr/local/mysql', or '/usr/local/mysql-${instance}' for instance
+	DataDir  string // default is '${baseDir}/data'
+	TmpDir   string // default is system temporary dir
+	StateDir string // dir to keep installation state to resume, default is '/var/lib/foolish-mysql'
+
+	// server
+	Instance       string            // instance name, used to run multiple mysql servers on one host
+	Port           int               // default is 3306
+	Socket         string            // default is '/tmp/mysql.sock', or '/tmp/mysql-${instance}.sock' for instance
+	MyCnf          map[string]string // extra options of [mysqld] section in my.cnf
+	Dependencies   DependencyPolicy  // default is DependenciesInstall
+	RootPassword   string            // fixed root password, a random one is generated if empty
+	PasswordLength int               // length of generated passwords, default is 32
+	Databases      []*DatabaseConfig // databases to create after root password is set
+	Users          []*UserConfig     // users to create after root password is set, passwords are generated if empty
+	StartTimeout   time.Duration     // time to wait for the started server to accept connections, default is 2 minutes
+
+	// hardening
+	NoHardening    bool // keep anonymous accounts, test database and remote root created by mysqld
+	RootAuthSocket bool // switch 'root'@'localhost' to auth_socket, root password will not work any more
+
+	// service
+	NoService      bool   // do not register systemd service
+	ServiceRestart string // Restart= option of service unit, default is 'on-failure'
+
+	// steps
+	Resume    bool       // continue the last failed installation of the instance from its last successful step
+	OnlySteps []StepName // only run these steps
+	SkipSteps []StepName // bypass these steps
+	DryRun    bool       // only check the system and plan changes without making them, see Result.Plan
+
+	// hooks
+	Reporter Reporter // receiver of logs, warnings, step events and progress, default is console reporter
+	Hooks    *Hooks   // callbacks called after Reporter
+}
+
+// Hooks callbacks of installation, nil functions are ignored
+type Hooks struct {
+	OnStepStarted  func(step StepName)
+	OnStepFinished func(event *StepEvent)
+	OnProgress     func(task string, bytes int64, total int64)
+	OnLog          func(message string)
+	OnWarning      func(message string)
+}
+
+// reporter calling hooks after the wrapped reporter
+type hooksReporter struct {
+	reporter Reporter
+	hooks    *Hooks
+}
+
+func (this *hooksReporter) StepStarted(step StepName) {
+	this.reporter.StepStarted(step)
+	if this.hooks.OnStepStarted != nil {
+		this.hooks.OnStepStarted(step)
+	}
+}
+
+func (this *hooksReporter) StepFinished(event *StepEvent) {
+	this.reporter.StepFinished(event)
+	if this.hooks.OnStepFinished != nil {
+		this.hooks.OnStepFinished(event)
+	}
+}
+
+func (this *hooksReporter) Progress(task string, bytes int64, total int64) {
+	this.reporter.Progress(task, bytes, total)
+	if this.hooks.OnProgress != nil {
+		this.hooks.OnProgress(task, bytes, total)
+	}
+}
+
+func (this *hooksReporter) Log(message string) {
+	this.reporter.Log(message)
+	if this.hooks.OnLog != nil {
+		this.hooks.OnLog(message)
+	}
+}
+
+func (this *hooksReporter) Warning(message string) {
+	this.reporter.Warning(message)
+	if this.hooks.OnWarning != nil {
+		this.hooks.OnWarning(message)
+	}
+}